@@ -1,15 +1,14 @@
 package config
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"reflect"
 	"sync"
 	"time"
-
-	"github.com/imdario/mergo"
 )
 
 var (
@@ -17,8 +16,26 @@ var (
 	ErrScanNeedPtr       = errors.New("scan need ptr")
 	ErrNotFound          = errors.New("key not found")
 	ErrTypeAssert        = errors.New("type assert error")
+	ErrRequired          = errors.New("required key is missing")
 )
 
+// errorsBuffer is the capacity of the Errors channel.
+const errorsBuffer = 16
+
+// SourceError is a failure of a config source watcher.
+type SourceError struct {
+	Source string
+	Err    error
+}
+
+func (e *SourceError) Error() string {
+	return fmt.Sprintf("config source %s: %v", e.Source, e.Err)
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
 var (
 	SupportedFormats = []string{"env", "json", "xml", "yaml", "yml"}
 )
@@ -26,25 +43,55 @@ var (
 // Observer is config observer.
 type Observer func(Config)
 
+// KeyObserver is config key observer, it receives the
+// old and new value of the watched key when it changes.
+type KeyObserver func(old, new Value)
+
 // Config is a config interface.
 type Config interface {
 	Load() error
+	LoadContext(ctx context.Context) error
 	Scan(v interface{}) error
 	Watch(o Observer) error
+	WatchContext(ctx context.Context, o Observer) error
+	WatchKey(key string, o KeyObserver) error
 	Close() error
 	Get(key string) Value
+	Snapshot() Snapshot
+	Errors() <-chan error
 }
 
 var _ Config = (*config)(nil)
 
 type config struct {
-	opts        options
-	descriptors sync.Map
-	observers   []Observer
-	watchers    []Watcher
-	mu          sync.Mutex
-	reader      Reader
-	cached      sync.Map
+	opts       options
+	observers  []*observer
+	keyWatches []*keyObserver
+	watchers   []Watcher
+	cancels    []context.CancelFunc
+	files      *filesWatcher
+	mu         sync.Mutex
+	reloadMu   sync.Mutex
+	readerMu   sync.RWMutex
+	reader     *reader
+	revision   uint64
+	snapMu     sync.Mutex
+	snap       *snapshot
+	cached     sync.Map
+	errs       chan error
+
+	// ctx is of the last load, the watchers started later derive from it
+	ctx context.Context
+}
+
+type observer struct {
+	ctx context.Context
+	fn  Observer
+}
+
+type keyObserver struct {
+	key string
+	fn  KeyObserver
 }
 
 // New new a config with options.
@@ -52,6 +99,9 @@ func New(opts ...Option) Config {
 	o := options{
 		decoder:  defaultDecoder,
 		resolver: defaultResolver,
+		logger:   NopLogger,
+		metrics:  NopMetrics,
+		backoff:  DefaultBackoff,
 	}
 
 	for _, opt := range opts {
@@ -61,112 +111,154 @@ func New(opts ...Option) Config {
 	return &config{
 		opts:   o,
 		reader: newReader(o),
+		errs:   make(chan error, errorsBuffer),
 	}
 }
 
 func (c *config) Load() error {
-	for _, src := range c.opts.sources {
-		descriptors, err := src.Load()
+	return c.LoadContext(context.Background())
+}
+
+// LoadContext loads all sources and starts their watchers.
+// Cancelling ctx aborts loading and stops the watchers.
+func (c *config) LoadContext(ctx context.Context) (err error) {
+	c.mu.Lock()
+	c.ctx = ctx
+	started := len(c.watchers)
+	c.mu.Unlock()
+	defer func() {
 		if err != nil {
+			// do not leak the watchers of a failed load
+			_ = c.stopWatchers(started)
+		}
+	}()
+
+	for i, src := range c.opts.sources {
+		name := sourceName(src)
+		logger := WithFields(c.logger(), "source", name)
+		if ls, ok := src.(LoggerSetter); ok {
+			ls.SetLogger(logger)
+		}
+
+		start := time.Now()
+		descriptors, err := loadSource(ctx, src)
+		c.metrics().LoadDuration(name, time.Since(start), err)
+		if err != nil {
+			logger.Error("failed to load config source", "error", err)
 			return err
 		}
 
+		priority := c.opts.priority(i)
 		for _, d := range descriptors {
-			if c.opts.enableLog {
-				fmt.Printf("load config: name: %s format: %s\n", d.Name, d.Format)
-			}
-			c.descriptors.Store(d.Name, d)
+			d.Priority, d.source = priority, i
+			logger.Info("load config", "name", d.Name, "format", d.Format, "priority", priority)
 		}
+		logger.Debug("loaded config source", "descriptors", len(descriptors), "duration", time.Since(start))
 
-		if err = c.reader.Merge(descriptors...); err != nil {
-			return fmt.Errorf("failed to watch config source: %v", err)
+		c.reloadMu.Lock()
+		err = c.current().Merge(descriptors...)
+		c.bumpRevision()
+		c.reloadMu.Unlock()
+		if err != nil {
+			c.metrics().MergeFailure(name)
+			logger.Error("failed to merge config source", "error", err)
+			return fmt.Errorf("failed to merge config source: %v", err)
 		}
 
-		w, err := src.Watch()
+		w, err := watchSource(ctx, src)
 
 		if err != nil {
 			return fmt.Errorf("failed to watch config source: %v", err)
 		}
 
 		if w != nil {
-			c.watchers = append(c.watchers, w)
-			go c.watch(w)
+			c.startWatch(ctx, w, name, i, priority)
 		}
 	}
 
-	if err := c.reader.Resolve(); err != nil {
+	c.reloadMu.Lock()
+	err = c.current().Resolve()
+	c.bumpRevision()
+	c.reloadMu.Unlock()
+	if err != nil {
+		c.metrics().ResolveFailure("")
+		c.logger().Error("failed to resolve config", "error", err)
 		return fmt.Errorf("failed to resolve config source: %v", err)
 	}
 
+	if err := c.watchFiles(ctx); err != nil {
+		return fmt.Errorf("failed to watch referenced files: %v", err)
+	}
 	return nil
 }
 
 func (c *config) Scan(v interface{}) error {
-	if reflect.TypeOf(v).Kind() != reflect.Ptr {
-		return ErrScanNeedPtr
-	}
-
-	rvt := reflect.TypeOf(v).Elem()
-
-	var envV = reflect.New(rvt).Interface()
-	if ed, ok := c.descriptors.LoadAndDelete("environ"); ok {
-		c.scanTo(ed.(*Descriptor), envV)
-	}
-
-	var flagV = reflect.New(rvt).Interface()
-	if fd, ok := c.descriptors.LoadAndDelete("flag"); ok {
-		c.scanTo(fd.(*Descriptor), flagV)
-	}
-
-	var fileV = reflect.New(rvt).Interface()
-	data, err := c.reader.Source()
+	// the reader already merged all sources by their priority
+	data, err := c.current().Source()
 	if err != nil {
 		return err
 	}
-	if err := unmarshalJSON(data, fileV); err != nil {
-		return err
-	}
+	return scanSource(data, v, c.opts.durationUnit)
+}
 
-	// order config source: flag > env > file
-	if err := mergo.Merge(v, fileV, mergo.WithOverride); err != nil {
-		return err
+func scanSource(data []byte, v interface{}, unit time.Duration) error {
+	if reflect.TypeOf(v).Kind() != reflect.Ptr {
+		return ErrScanNeedPtr
 	}
-
-	if err := mergo.Merge(v, envV, mergo.WithOverride); err != nil {
+	if err := scanJSON(data, v, unit); err != nil {
 		return err
 	}
-
-	if err := mergo.Merge(v, flagV, mergo.WithOverride); err != nil {
+	var src interface{}
+	if err := json.Unmarshal(data, &src); err != nil {
 		return err
 	}
-
-	return nil
+	return checkStruct(v, src, unit)
 }
 
-func (c *config) scanTo(d *Descriptor, v interface{}) error {
-	codec := d.GetCodec()
-	if codec == nil {
-		log.Printf("failed to get codec: name: %s format: %s", d.Name, d.Format)
-		return fmt.Errorf("failed to get codec: name: %s format: %s", d.Name, d.Format)
-	}
-	nv := reflect.New(reflect.TypeOf(v).Elem()).Interface()
-	if err := codec.Unmarshal(d.Data, nv); err != nil {
-		return fmt.Errorf("failed to unmarshal config: name: %s format: %s err: %v", d.Name, d.Format, err)
+func (c *config) Watch(o Observer) error {
+	return c.WatchContext(context.Background(), o)
+}
 
-	}
-	if err := mergo.Merge(v, nv); err != nil {
-		return fmt.Errorf("failed to merge config: name: %s format: %s err: %v", d.Name, d.Format, err)
-	}
+// WatchContext registers an observer until ctx is done.
+func (c *config) WatchContext(ctx context.Context, o Observer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.observers = append(c.observers, &observer{ctx: ctx, fn: o})
 	return nil
 }
 
-func (c *config) Watch(o Observer) error {
-	c.observers = append(c.observers, o)
+// WatchKey registers an observer which is only called
+// when the value of the given key changes after a reload.
+func (c *config) WatchKey(key string, o KeyObserver) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.keyWatches = append(c.keyWatches, &keyObserver{key: key, fn: o})
 	return nil
 }
 
 func (c *config) Close() error {
-	for _, w := range c.watchers {
+	return c.stopWatchers(0)
+}
+
+// stopWatchers stops the watchers started after the first n.
+func (c *config) stopWatchers(n int) error {
+	c.mu.Lock()
+	if n > len(c.watchers) {
+		n = len(c.watchers)
+	}
+	cancels, watchers := c.cancels[n:], c.watchers[n:]
+	c.cancels, c.watchers = c.cancels[:n:n], c.watchers[:n:n]
+	for _, w := range watchers {
+		if ow, ok := w.(*onceWatcher); ok && ow.Watcher == c.files {
+			c.files = nil
+		}
+	}
+	c.mu.Unlock()
+
+	for _, cancel := range cancels {
+		cancel()
+	}
+	for _, w := range watchers {
 		if err := w.Stop(); err != nil {
 			return err
 		}
@@ -178,53 +270,317 @@ func (c *config) Get(key string) Value {
 	if v, ok := c.cached.Load(key); ok {
 		return v.(Value)
 	}
-	if v, ok := c.reader.Value(key); ok {
+	if v, ok := c.current().Value(key); ok {
 		c.cached.Store(key, v)
 		return v
 	}
 	return &errValue{err: ErrNotFound}
 }
 
-func (c *config) watch(w Watcher) {
+// loadContext returns the context of the last load.
+func (c *config) loadContext() context.Context {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+func (c *config) startWatch(ctx context.Context, w Watcher, name string, source, priority int) {
+	ctx, cancel := context.WithCancel(ctx)
+	ow := &onceWatcher{Watcher: w}
+
+	c.mu.Lock()
+	c.watchers = append(c.watchers, ow)
+	c.cancels = append(c.cancels, cancel)
+	c.mu.Unlock()
+
+	go func() {
+		// unblock watchers which are not context aware
+		<-ctx.Done()
+		_ = ow.Stop()
+	}()
+	go c.watch(ctx, ow, name, source, priority)
+}
+
+// notify calls the observers which are still registered.
+func (c *config) notify() {
+	c.mu.Lock()
+	observers := c.observers[:0]
+	for _, o := range c.observers {
+		if o.ctx.Err() == nil {
+			observers = append(observers, o)
+		}
+	}
+	c.observers = observers
+	observers = append([]*observer(nil), observers...)
+	c.mu.Unlock()
+
+	for _, o := range observers {
+		func() {
+			defer c.guard()
+			o.fn(c)
+		}()
+	}
+}
+
+// watchedValues returns the current values of all watched keys.
+func (c *config) watchedValues() map[string]Value {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	values := make(map[string]Value, len(c.keyWatches))
+	for _, o := range c.keyWatches {
+		if v, ok := c.current().Value(o.key); ok {
+			values[o.key] = v
+		}
+	}
+	return values
+}
+
+// notifyKeys calls the key observers whose value differs from prev.
+func (c *config) notifyKeys(prev map[string]Value) {
+	c.mu.Lock()
+	watches := append([]*keyObserver(nil), c.keyWatches...)
+	c.mu.Unlock()
+
+	for _, o := range watches {
+		old, hadOld := prev[o.key]
+		cur, hasCur := c.current().Value(o.key)
+		if hadOld == hasCur && (!hasCur || reflect.DeepEqual(old.Load(), cur.Load())) {
+			continue
+		}
+		if !hadOld {
+			old = &errValue{err: ErrNotFound}
+		}
+		if !hasCur {
+			cur = &errValue{err: ErrNotFound}
+		}
+		func() {
+			defer c.guard()
+			o.fn(old, cur)
+		}()
+	}
+}
+
+func (c *config) watch(ctx context.Context, w *onceWatcher, name string, source, priority int) {
+	logger := WithFields(c.logger(), "source", name)
+	apply := func(descriptors []*Descriptor) {
+		if err := c.reload(name, descriptors); err != nil {
+			logger.Warn("rejected config reload", "error", err)
+			c.report(name, err)
+			for _, h := range c.opts.reloadErrorHandlers {
+				h(err)
+			}
+		}
+	}
+	if c.opts.debounce > 0 {
+		changes := make(chan []*Descriptor)
+		go coalesce(ctx, changes, c.opts.debounce, apply)
+		apply = func(descriptors []*Descriptor) {
+			select {
+			case changes <- descriptors:
+			case <-ctx.Done():
+			}
+		}
+	}
+	var attempt int
 	for {
-		descriptors, err := w.Next()
-		if errors.Is(err, context.Canceled) {
-			fmt.Println("watcher's ctx cancel", err)
+		descriptors, err := w.NextContext(ctx)
+		if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+			logger.Debug("watcher stopped", "reason", err)
 			return
 		}
 		if err != nil {
-			time.Sleep(time.Second)
-			fmt.Println("failed to watch next config", err)
-			continue
-		}
-		if err := c.reader.Merge(descriptors...); err != nil {
-			fmt.Println("failed to merge next config", err)
+			delay := c.opts.backoff.delay(attempt)
+			attempt++
+			logger.Error("failed to watch next config", "error", err, "attempt", attempt, "retry", delay)
+			c.report(name, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
 			continue
 		}
-		if err := c.reader.Resolve(); err != nil {
-			fmt.Println("failed to resolve next config", err)
-			continue
+		attempt = 0
+		for _, d := range descriptors {
+			d.Priority, d.source = priority, source
+			logger.Debug("config changed", "name", d.Name, "format", d.Format)
 		}
+		apply(descriptors)
+	}
+}
 
-		c.cached.Range(func(key, value interface{}) bool {
-			k := key.(string)
-			v := value.(Value)
-			if n, ok := c.reader.Value(k); ok && reflect.TypeOf(n.Load()) == reflect.TypeOf(v.Load()) && !reflect.DeepEqual(n.Load(), v.Load()) {
-				v.Store(n.Load())
-				c.cached.Store(k, v)
+// coalesce applies the changes received from in once no more
+// changes arrive within window, later descriptors replace
+// the pending ones of the same name.
+func coalesce(ctx context.Context, in <-chan []*Descriptor, window time.Duration, apply func([]*Descriptor)) {
+	var (
+		pending []*Descriptor
+		timer   = time.NewTimer(window)
+	)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case descriptors := <-in:
+			for _, d := range descriptors {
+				pending = appendDescriptor(pending, d)
 			}
-			return true
-		})
-
-		for _, d := range descriptors {
-			if v, ok := c.descriptors.Load(d.Name); ok {
-				if !reflect.DeepEqual(v, d) {
-					c.descriptors.Store(d.Name, d)
-					for _, o := range c.observers {
-						o(c)
-					}
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
 				}
 			}
+			timer.Reset(window)
+		case <-timer.C:
+			apply(pending)
+			pending = nil
+		}
+	}
+}
+
+func appendDescriptor(ds []*Descriptor, d *Descriptor) []*Descriptor {
+	for i, e := range ds {
+		if e.Name == d.Name && e.source == d.source {
+			ds[i] = d
+			return ds
 		}
 	}
+	return append(ds, d)
+}
+
+// Errors returns the channel of watcher failures, each wrapped in a
+// *SourceError. Errors are dropped when the channel is full, and the
+// channel is never closed.
+func (c *config) Errors() <-chan error {
+	return c.errs
+}
+
+// report passes a watcher failure to the error handlers and Errors.
+func (c *config) report(source string, err error) {
+	for _, h := range c.opts.errorHandlers {
+		h(source, err)
+	}
+	select {
+	case c.errs <- &SourceError{Source: source, Err: err}:
+	default:
+	}
+}
+
+func (c *config) logger() Logger {
+	if c.opts.logger == nil {
+		return NopLogger
+	}
+	return c.opts.logger
+}
+
+func (c *config) metrics() Metrics {
+	if c.opts.metrics == nil {
+		return NopMetrics
+	}
+	return c.opts.metrics
+}
+
+// guard recovers from a panic of an observer, so
+// it can not break the watcher or other observers.
+func (c *config) guard() {
+	if r := recover(); r != nil {
+		c.metrics().ObserverPanic()
+		c.logger().Error("observer panic", "panic", r)
+	}
+}
+
+// current returns the live reader.
+func (c *config) current() *reader {
+	r, _ := c.live()
+	return r
+}
+
+// live returns the live reader and its revision.
+func (c *config) live() (*reader, uint64) {
+	c.readerMu.RLock()
+	defer c.readerMu.RUnlock()
+	return c.reader, c.revision
+}
+
+// bumpRevision marks the live reader as modified in place.
+func (c *config) bumpRevision() {
+	c.readerMu.Lock()
+	c.revision++
+	revision := c.revision
+	c.readerMu.Unlock()
+	c.metrics().Revision(revision)
+}
+
+// reload merges descriptors into a candidate of the live reader and runs
+// the reload validators on it. The candidate only replaces the live reader
+// when it is valid, otherwise the last known good config is kept.
+func (c *config) reload(source string, descriptors []*Descriptor) (err error) {
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+	defer func() {
+		c.metrics().Reload(source, err)
+	}()
+
+	live := c.current()
+	next := live.fork()
+	if err := next.Merge(descriptors...); err != nil {
+		c.metrics().MergeFailure(source)
+		return fmt.Errorf("failed to merge next config: %w", err)
+	}
+	if err := next.Resolve(); err != nil {
+		c.metrics().ResolveFailure(source)
+		return fmt.Errorf("failed to resolve next config: %w", err)
+	}
+	candidate := &config{opts: c.opts, reader: next}
+	for _, validate := range c.opts.reloadValidators {
+		if err := validate(candidate); err != nil {
+			return fmt.Errorf("rejected next config: %w", err)
+		}
+	}
+
+	prev := c.watchedValues()
+	before, _ := live.Source()
+
+	c.readerMu.Lock()
+	c.reader = next
+	c.revision++
+	revision := c.revision
+	c.readerMu.Unlock()
+	c.metrics().Revision(revision)
+	c.logger().Info("config reloaded", "revision", revision)
+
+	c.refreshCache()
+	c.notifyKeys(prev)
+	if err := c.watchFiles(c.loadContext()); err != nil {
+		c.logger().Warn("failed to watch referenced files", "error", err)
+	}
+
+	if after, err := next.Source(); err == nil && !bytes.Equal(before, after) {
+		c.notify()
+	}
+	return nil
+}
+
+// refreshCache updates the cached values returned by Get after a reload,
+// keys which are removed or whose type changed are dropped from the cache.
+func (c *config) refreshCache() {
+	c.cached.Range(func(key, value interface{}) bool {
+		k := key.(string)
+		v := value.(Value)
+		n, ok := c.current().Value(k)
+		if !ok || reflect.TypeOf(n.Load()) != reflect.TypeOf(v.Load()) {
+			c.cached.Delete(k)
+			return true
+		}
+		if !reflect.DeepEqual(n.Load(), v.Load()) {
+			v.Store(n.Load())
+		}
+		return true
+	})
 }
@@ -1,9 +1,13 @@
 package config
 
 import (
+	"context"
 	"errors"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 )
 
 const (
@@ -183,3 +187,534 @@ func TestConfig(t *testing.T) {
 		t.Fatal(`len(testConf.Endpoints) is not equal to 2`)
 	}
 }
+
+type testNamedSource struct {
+	name   string
+	data   string
+	format string
+}
+
+func (s *testNamedSource) Load() ([]*Descriptor, error) {
+	format := s.format
+	if format == "" {
+		format = "json"
+	}
+	return []*Descriptor{{Name: s.name, Data: []byte(s.data), Format: format}}, nil
+}
+
+func (s *testNamedSource) Watch() (Watcher, error) {
+	return nil, nil
+}
+
+func TestConfig_Priority(t *testing.T) {
+	var (
+		base   = &testNamedSource{name: "base", data: `{"level": "info", "port": 80}`}
+		prod   = &testNamedSource{name: "prod", data: `{"level": "warn"}`}
+		remote = &testNamedSource{name: "remote", data: `{"level": "debug", "port": 8080}`}
+	)
+
+	tests := []struct {
+		name  string
+		opts  []Option
+		level string
+		port  int
+	}{
+		{
+			name:  "registration order",
+			opts:  []Option{WithSource(base, prod)},
+			level: "warn",
+			port:  80,
+		},
+		{
+			name:  "reversed registration order",
+			opts:  []Option{WithSource(prod, base)},
+			level: "info",
+			port:  80,
+		},
+		{
+			name:  "explicit priority",
+			opts:  []Option{WithSource(remote, base, prod), WithPriority(remote, 10)},
+			level: "debug",
+			port:  8080,
+		},
+		{
+			name:  "explicit low priority",
+			opts:  []Option{WithSource(base, prod, remote), WithPriority(remote, -1)},
+			level: "warn",
+			port:  80,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := New(test.opts...)
+			if err := c.Load(); err != nil {
+				t.Fatal(err)
+			}
+			var conf struct {
+				Level string `json:"level"`
+				Port  int    `json:"port"`
+			}
+			if err := c.Scan(&conf); err != nil {
+				t.Fatal(err)
+			}
+			if conf.Level != test.level || conf.Port != test.port {
+				t.Fatalf("expect %s %d, got %+v", test.level, test.port, conf)
+			}
+			if level, _ := c.Get("level").String(); level != test.level {
+				t.Fatalf("expect %s, got %s", test.level, level)
+			}
+		})
+	}
+}
+
+func TestConfig_PrioritySameName(t *testing.T) {
+	var (
+		s1 = &testNamedSource{name: "config.yaml", data: `{"a": 1, "level": "info"}`}
+		s2 = &testNamedSource{name: "config.yaml", data: `{"b": 2, "level": "warn"}`}
+		s3 = &testNamedSource{name: "config.yaml", data: `{"c": 3}`}
+	)
+	// s2 and s3 share the default priority of s1
+	c := New(WithSource(s1, s2, s3), WithPriority(s2, 0), WithPriority(s3, 0))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	for key, expect := range map[string]string{"a": "1", "b": "2", "c": "3", "level": "warn"} {
+		if actual, err := c.Get(key).String(); err != nil || actual != expect {
+			t.Errorf("%s: expect %s, actual %s %v", key, expect, actual, err)
+		}
+	}
+}
+
+func TestConfig_ScanDuration(t *testing.T) {
+	c := New(WithSource(newTestJSONSource(`{"server": {"timeout": "1.5s", "idle": 30}}`)))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var conf struct {
+		Server struct {
+			Timeout time.Duration
+			Idle    time.Duration `json:"idle"`
+		} `json:"server"`
+	}
+	if err := c.Scan(&conf); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Server.Timeout != 1500*time.Millisecond || conf.Server.Idle != 30 {
+		t.Fatalf("unexpected durations: %+v", conf.Server)
+	}
+}
+
+func TestConfig_DurationUnit(t *testing.T) {
+	source := newTestJSONSource(`{"server": {"timeout": 30, "idle": "2"}}`)
+	c := New(WithSource(source), WithDurationUnit(time.Second))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var conf struct {
+		Server struct {
+			Timeout time.Duration `json:"timeout"`
+			Idle    time.Duration `json:"idle"`
+		} `json:"server"`
+	}
+	if err := c.Scan(&conf); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Server.Timeout != 30*time.Second || conf.Server.Idle != 2*time.Second {
+		t.Fatalf("expect 30s and 2s, got %+v", conf.Server)
+	}
+	if d, err := c.Get("server.timeout").Duration(); err != nil || d != 30*time.Second {
+		t.Fatalf("expect 30s, got %v %v", d, err)
+	}
+	if d, err := c.Snapshot().Get("server.idle").Duration(); err != nil || d != 2*time.Second {
+		t.Fatalf("expect 2s, got %v %v", d, err)
+	}
+
+	// the unit is not shared with other configs
+	other := New(WithSource(source))
+	if err := other.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if d, err := other.Get("server.timeout").Duration(); err != nil || d != 30 {
+		t.Fatalf("expect 30ns, got %v %v", d, err)
+	}
+}
+
+func TestConfig_LoadContext(t *testing.T) {
+	c := New(WithSource(newTestJSONSource(_testJSON)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.LoadContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expect context.Canceled, got %v", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	if err := c.LoadContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	w := c.(*config).watchers[0].(*onceWatcher).Watcher.(*testWatcher)
+	cancel()
+
+	select {
+	case <-w.exit:
+	case <-time.After(time.Second):
+		t.Fatal("watcher is not stopped after ctx cancel")
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfig_WatchContext(t *testing.T) {
+	c := New().(*config)
+
+	var called int
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := c.WatchContext(ctx, func(Config) { called++ }); err != nil {
+		t.Fatal(err)
+	}
+
+	c.notify()
+	cancel()
+	c.notify()
+
+	if called != 1 {
+		t.Fatalf("expect observer called once, got %d", called)
+	}
+	if len(c.observers) != 0 {
+		t.Fatalf("expect observer removed, got %d", len(c.observers))
+	}
+}
+
+type testChanSource struct {
+	data string
+	next chan []*Descriptor
+	exit chan struct{}
+}
+
+func newTestChanSource(data string) *testChanSource {
+	return &testChanSource{data: data, next: make(chan []*Descriptor), exit: make(chan struct{})}
+}
+
+func (s *testChanSource) Load() ([]*Descriptor, error) {
+	return []*Descriptor{{Name: "json", Data: []byte(s.data), Format: "json"}}, nil
+}
+
+func (s *testChanSource) Watch() (Watcher, error) {
+	return s, nil
+}
+
+func (s *testChanSource) Next() ([]*Descriptor, error) {
+	select {
+	case ds := <-s.next:
+		return ds, nil
+	case <-s.exit:
+		return nil, context.Canceled
+	}
+}
+
+func (s *testChanSource) Stop() error {
+	close(s.exit)
+	return nil
+}
+
+func TestConfig_WatchKey(t *testing.T) {
+	src := newTestChanSource(`{"server": {"addr": ":80", "port": 80}}`)
+	c := New(WithSource(src))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	changed := make(chan [2]interface{}, 2)
+	for _, key := range []string{"server.addr", "server.port"} {
+		key := key
+		if err := c.WatchKey(key, func(old, new Value) {
+			changed <- [2]interface{}{key, new.Load()}
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	src.next <- []*Descriptor{{Name: "json", Data: []byte(`{"server": {"addr": ":80", "port": 8080}}`), Format: "json"}}
+
+	select {
+	case got := <-changed:
+		if !reflect.DeepEqual(got, [2]interface{}{"server.port", float64(8080)}) {
+			t.Fatalf("unexpected change %v", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("key observer is not called")
+	}
+	select {
+	case got := <-changed:
+		t.Fatalf("unexpected change %v", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestConfig_ReloadGet(t *testing.T) {
+	src := newTestChanSource(`{"server": {"addr": ":80", "port": 80}}`)
+	c := New(WithSource(src))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	addr := c.Get("server.addr")
+	if port, _ := c.Get("server.port").Int(); port != 80 {
+		t.Fatalf("expect 80, got %d", port)
+	}
+
+	reloaded := make(chan struct{}, 1)
+	_ = c.Watch(func(Config) { reloaded <- struct{}{} })
+	src.next <- []*Descriptor{{Name: "json", Data: []byte(`{"server": {"addr": ":8080", "port": "http"}}`), Format: "json"}}
+	select {
+	case <-reloaded:
+	case <-time.After(time.Second):
+		t.Fatal("observer is not called")
+	}
+
+	if s, _ := addr.String(); s != ":8080" {
+		t.Fatalf("expect cached value updated, got %s", s)
+	}
+	if s, _ := c.Get("server.port").String(); s != "http" {
+		t.Fatalf("expect http, got %s", s)
+	}
+
+	var conf struct {
+		Server struct {
+			Addr string `json:"addr"`
+		} `json:"server"`
+	}
+	if err := c.Scan(&conf); err != nil || conf.Server.Addr != ":8080" {
+		t.Fatalf("expect :8080, got %q %v", conf.Server.Addr, err)
+	}
+}
+
+func TestConfig_ReloadValidator(t *testing.T) {
+	src := newTestChanSource(`{"server": {"port": 80}}`)
+	rejected := make(chan error, 1)
+	c := New(
+		WithSource(src),
+		WithReloadValidator(func(c Config) error {
+			if port, _ := c.Get("server.port").Int(); port <= 0 {
+				return errors.New("invalid port")
+			}
+			return nil
+		}),
+		WithReloadErrorHandler(func(err error) { rejected <- err }),
+	)
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	reloaded := make(chan struct{}, 1)
+	_ = c.Watch(func(Config) { reloaded <- struct{}{} })
+
+	src.next <- []*Descriptor{{Name: "json", Data: []byte(`{"server": {"port": 0}}`), Format: "json"}}
+	select {
+	case err := <-rejected:
+		if err == nil {
+			t.Fatal("expect error")
+		}
+	case <-reloaded:
+		t.Fatal("invalid config is applied")
+	case <-time.After(time.Second):
+		t.Fatal("error handler is not called")
+	}
+	if port, _ := c.Get("server.port").Int(); port != 80 {
+		t.Fatalf("expect last known good port 80, got %d", port)
+	}
+
+	src.next <- []*Descriptor{{Name: "json", Data: []byte(`{"server": {"port": 8080}}`), Format: "json"}}
+	select {
+	case <-reloaded:
+	case err := <-rejected:
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("observer is not called")
+	}
+	if port, _ := c.Get("server.port").Int(); port != 8080 {
+		t.Fatalf("expect port 8080, got %d", port)
+	}
+}
+
+func TestConfig_Errors(t *testing.T) {
+	src := newTestJSONSource(_testJSON)
+	handled := make(chan string, 1)
+	c := New(
+		WithSource(src),
+		WithErrorHandler(func(source string, err error) { handled <- source }),
+		WithBackoff(Backoff{Initial: time.Millisecond, Max: time.Millisecond, Multiplier: 2}),
+	)
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	src.err <- struct{}{}
+
+	select {
+	case source := <-handled:
+		if source != "*config.testJSONSource" {
+			t.Fatalf("unexpected source %q", source)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("error handler is not called")
+	}
+
+	select {
+	case err := <-c.Errors():
+		var se *SourceError
+		if !errors.As(err, &se) || se.Source != "*config.testJSONSource" || se.Err.Error() != "error" {
+			t.Fatalf("unexpected error %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("error is not sent")
+	}
+}
+
+type testMetrics struct {
+	nopMetrics
+	reloads chan error
+	panics  chan struct{}
+}
+
+func (m *testMetrics) Reload(source string, err error) { m.reloads <- err }
+func (m *testMetrics) ObserverPanic()                  { m.panics <- struct{}{} }
+
+func TestConfig_Metrics(t *testing.T) {
+	src := newTestChanSource(`{"a": 1}`)
+	m := &testMetrics{reloads: make(chan error, 1), panics: make(chan struct{}, 2)}
+	c := New(WithSource(src), WithMetrics(m))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	called := make(chan struct{}, 1)
+	_ = c.Watch(func(Config) { panic("boom") })
+	_ = c.Watch(func(Config) { called <- struct{}{} })
+	src.next <- []*Descriptor{{Name: "json", Data: []byte(`{"a": 2}`), Format: "json"}}
+
+	select {
+	case <-called:
+	case <-time.After(time.Second):
+		t.Fatal("observer after a panicking observer is not called")
+	}
+	select {
+	case <-m.panics:
+	case <-time.After(time.Second):
+		t.Fatal("observer panic is not reported")
+	}
+	select {
+	case err := <-m.reloads:
+		if err != nil {
+			t.Fatalf("unexpected reload error %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("reload is not reported")
+	}
+}
+
+func TestConfig_ReloadDebounce(t *testing.T) {
+	src := newTestChanSource(`{"a": 1}`)
+	m := &testMetrics{reloads: make(chan error, 4), panics: make(chan struct{}, 1)}
+	c := New(WithSource(src), WithMetrics(m), WithReloadDebounce(50*time.Millisecond))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	called := make(chan struct{}, 4)
+	_ = c.Watch(func(Config) { called <- struct{}{} })
+	for _, data := range []string{`{"a": 2}`, `{"a": 3}`, `{"a": 4}`} {
+		src.next <- []*Descriptor{{Name: "json", Data: []byte(data), Format: "json"}}
+	}
+
+	select {
+	case <-called:
+	case <-time.After(time.Second):
+		t.Fatal("observer is not called")
+	}
+	if v, _ := c.Get("a").Int(); v != 4 {
+		t.Fatalf("expect 4, got %d", v)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := len(called); n != 0 {
+		t.Fatalf("expect one notification, got %d more", n)
+	}
+	if n := len(m.reloads); n != 1 {
+		t.Fatalf("expect one reload, got %d", n)
+	}
+}
+
+func TestConfig_LoadFailureStopsWatchers(t *testing.T) {
+	src := newTestChanSource(`{"a": 1}`)
+	c := New(WithSource(src, &testNamedSource{name: "bad", data: `{`}))
+	if err := c.Load(); err == nil {
+		t.Fatal("expect a merge error")
+	}
+	select {
+	case <-src.exit:
+	case <-time.After(2 * time.Second):
+		t.Fatal("watcher of the failed load is not stopped")
+	}
+	if n := len(c.(*config).watchers); n != 0 {
+		t.Fatalf("expect no watchers, got %d", n)
+	}
+}
+
+func TestConfig_FilesWatcherContext(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secret, []byte("s3cret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	src := newTestChanSource(`{}`)
+	c := New(WithSource(src))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := c.LoadContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// the placeholder appears on a reload, which starts the files watcher
+	changed := make(chan struct{}, 1)
+	if err := c.Watch(func(Config) { changed <- struct{}{} }); err != nil {
+		t.Fatal(err)
+	}
+	src.next <- []*Descriptor{{Name: "json", Format: "json", Data: []byte(`{"password": "${file:` + secret + `}"}`)}}
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("config is not reloaded")
+	}
+	c.(*config).mu.Lock()
+	files := c.(*config).files
+	c.(*config).mu.Unlock()
+	if files == nil {
+		t.Fatal("files watcher is not started")
+	}
+
+	cancel()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case _, ok := <-files.sw.Events():
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("files watcher is not stopped with the load context")
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Converter converts a config Value into a custom type.
+type Converter func(Value) (interface{}, error)
+
+var converters = struct {
+	sync.RWMutex
+	m map[reflect.Type]Converter
+}{m: make(map[reflect.Type]Converter)}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+	ipType       = reflect.TypeOf(net.IP{})
+)
+
+// RegisterConverter registers a converter used by GetAs for type T,
+// it takes precedence over the builtin conversions.
+func RegisterConverter[T any](fn func(Value) (T, error)) {
+	rt := reflect.TypeOf((*T)(nil)).Elem()
+	converters.Lock()
+	defer converters.Unlock()
+	converters.m[rt] = func(v Value) (interface{}, error) {
+		return fn(v)
+	}
+}
+
+// GetAs get the value of key converted to type T.
+func GetAs[T any](c Config, key string) (T, error) {
+	var t T
+	rv, err := convert(c.Get(key), reflect.TypeOf(&t).Elem())
+	if err != nil {
+		return t, fmt.Errorf("failed to get config: key: %s err: %w", key, err)
+	}
+	return rv.Interface().(T), nil
+}
+
+// MustGet is like GetAs but panics if the key is not found or can not be converted.
+func MustGet[T any](c Config, key string) T {
+	t, err := GetAs[T](c, key)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// GetOr is like GetAs but returns def if the key is not found or can not be converted.
+func GetOr[T any](c Config, key string, def T) T {
+	t, err := GetAs[T](c, key)
+	if err != nil {
+		return def
+	}
+	return t
+}
+
+// convert converts v into a new value of type rt.
+func convert(v Value, rt reflect.Type) (reflect.Value, error) {
+	if ev, ok := v.(*errValue); ok {
+		return reflect.Value{}, ev.err
+	}
+
+	out := reflect.New(rt).Elem()
+
+	converters.RLock()
+	fn, ok := converters.m[rt]
+	converters.RUnlock()
+	if ok {
+		i, err := fn(v)
+		if err != nil {
+			return out, err
+		}
+		if i != nil {
+			out.Set(reflect.ValueOf(i))
+		}
+		return out, nil
+	}
+
+	raw := v.Load()
+	if raw == nil {
+		return out, fmt.Errorf("convert nil to %v failed", rt)
+	}
+
+	switch rt {
+	case durationType:
+		d, err := v.Duration()
+		if err != nil {
+			return out, err
+		}
+		out.SetInt(int64(d))
+		return out, nil
+	case timeType:
+		switch val := raw.(type) {
+		case time.Time:
+			out.Set(reflect.ValueOf(val))
+			return out, nil
+		case string:
+			t, err := time.Parse(time.RFC3339Nano, val)
+			if err != nil {
+				return out, err
+			}
+			out.Set(reflect.ValueOf(t))
+			return out, nil
+		}
+		return out, fmt.Errorf("convert %v to %v failed", reflect.TypeOf(raw), rt)
+	case ipType:
+		s, err := v.String()
+		if err != nil {
+			return out, err
+		}
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return out, fmt.Errorf("invalid ip: %s", s)
+		}
+		out.Set(reflect.ValueOf(ip))
+		return out, nil
+	}
+
+	switch rt.Kind() {
+	case reflect.Bool:
+		b, err := v.Bool()
+		if err != nil {
+			return out, err
+		}
+		out.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := v.Int()
+		if err != nil {
+			return out, err
+		}
+		if out.OverflowInt(i) {
+			return out, fmt.Errorf("value %d overflows %v", i, rt)
+		}
+		out.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := uintValue(v)
+		if err != nil {
+			return out, err
+		}
+		if out.OverflowUint(u) {
+			return out, fmt.Errorf("value %d overflows %v", u, rt)
+		}
+		out.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := v.Float()
+		if err != nil {
+			return out, err
+		}
+		if out.OverflowFloat(f) {
+			return out, fmt.Errorf("value %v overflows %v", f, rt)
+		}
+		out.SetFloat(f)
+	case reflect.String:
+		s, err := v.String()
+		if err != nil {
+			return out, err
+		}
+		out.SetString(s)
+	case reflect.Interface:
+		if !reflect.TypeOf(raw).Implements(rt) {
+			return out, fmt.Errorf("convert %v to %v failed", reflect.TypeOf(raw), rt)
+		}
+		out.Set(reflect.ValueOf(raw))
+	case reflect.Ptr:
+		elem, err := convert(v, rt.Elem())
+		if err != nil {
+			return out, err
+		}
+		ptr := reflect.New(rt.Elem())
+		ptr.Elem().Set(elem)
+		out.Set(ptr)
+	case reflect.Slice:
+		if s, ok := raw.(string); ok && rt.Elem().Kind() == reflect.Uint8 {
+			out.SetBytes([]byte(s))
+			return out, nil
+		}
+		items, err := sliceValue(v)
+		if err != nil {
+			return out, err
+		}
+		out.Set(reflect.MakeSlice(rt, len(items), len(items)))
+		for i, item := range items {
+			elem, err := convert(item, rt.Elem())
+			if err != nil {
+				return out, fmt.Errorf("index %d: %w", i, err)
+			}
+			out.Index(i).Set(elem)
+		}
+	case reflect.Map:
+		items, err := v.Map()
+		if err != nil {
+			return out, err
+		}
+		out.Set(reflect.MakeMapWithSize(rt, len(items)))
+		for k, item := range items {
+			key, err := convert(newValue(k), rt.Key())
+			if err != nil {
+				return out, fmt.Errorf("key %s: %w", k, err)
+			}
+			elem, err := convert(item, rt.Elem())
+			if err != nil {
+				return out, fmt.Errorf("key %s: %w", k, err)
+			}
+			out.SetMapIndex(key, elem)
+		}
+	default:
+		if err := v.Scan(out.Addr().Interface()); err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+// uintValue converts v into uint64, rejecting negative numbers.
+func uintValue(v Value) (uint64, error) {
+	if s, ok := v.Load().(string); ok {
+		return strconv.ParseUint(s, 10, 64) //nolint:gomnd
+	}
+	i, err := v.Int()
+	if err != nil {
+		return 0, err
+	}
+	if i < 0 {
+		return 0, fmt.Errorf("negative value %d for unsigned type", i)
+	}
+	return uint64(i), nil
+}
+
+// sliceValue returns the items of v, a comma separated
+// string is treated as a list of strings.
+func sliceValue(v Value) ([]Value, error) {
+	s, ok := v.Load().(string)
+	if !ok {
+		return v.Slice()
+	}
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var items []Value
+	for _, item := range strings.Split(s, ",") {
+		items = append(items, newValue(strings.TrimSpace(item)))
+	}
+	return items, nil
+}
+
+func newValue(i interface{}) Value {
+	av := &atomicValue{}
+	av.Store(i)
+	return av
+}
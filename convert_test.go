@@ -0,0 +1,87 @@
+package config
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testUpper string
+
+func TestGetAs(t *testing.T) {
+	c := New(WithSource(newTestJSONSource(`{
+		"port": 8080,
+		"ratio": "0.5",
+		"hosts": ["a", "b"],
+		"tags": "x, y",
+		"limits": {"a": 1, "b": "2"},
+		"ip": "127.0.0.1",
+		"at": "2022-06-01T10:00:00Z",
+		"name": "sraph",
+		"negative": -1
+	}`)))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	RegisterConverter(func(v Value) (testUpper, error) {
+		s, err := v.String()
+		return testUpper(strings.ToUpper(s)), err
+	})
+
+	if v, err := GetAs[uint16](c, "port"); err != nil || v != 8080 {
+		t.Fatalf("port: %v %v", v, err)
+	}
+	if v, err := GetAs[float32](c, "ratio"); err != nil || v != 0.5 {
+		t.Fatalf("ratio: %v %v", v, err)
+	}
+	if v, err := GetAs[[]string](c, "hosts"); err != nil || !reflect.DeepEqual(v, []string{"a", "b"}) {
+		t.Fatalf("hosts: %v %v", v, err)
+	}
+	if v, err := GetAs[[]string](c, "tags"); err != nil || !reflect.DeepEqual(v, []string{"x", "y"}) {
+		t.Fatalf("tags: %v %v", v, err)
+	}
+	if v, err := GetAs[map[string]int](c, "limits"); err != nil || !reflect.DeepEqual(v, map[string]int{"a": 1, "b": 2}) {
+		t.Fatalf("limits: %v %v", v, err)
+	}
+	if v, err := GetAs[net.IP](c, "ip"); err != nil || !v.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Fatalf("ip: %v %v", v, err)
+	}
+	if v, err := GetAs[time.Time](c, "at"); err != nil || !v.Equal(time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("at: %v %v", v, err)
+	}
+	if v, err := GetAs[testUpper](c, "name"); err != nil || v != "SRAPH" {
+		t.Fatalf("name: %v %v", v, err)
+	}
+	if v, err := GetAs[*string](c, "name"); err != nil || *v != "sraph" {
+		t.Fatalf("name: %v %v", v, err)
+	}
+
+	if _, err := GetAs[uint](c, "negative"); err == nil {
+		t.Fatal("expect error for negative uint")
+	}
+	if _, err := GetAs[int8](c, "port"); err == nil {
+		t.Fatal("expect error for overflow")
+	}
+	if _, err := GetAs[int](c, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expect ErrNotFound, got %v", err)
+	}
+
+	if v := GetOr(c, "missing", 42); v != 42 {
+		t.Fatalf("expect default, got %v", v)
+	}
+	if v := MustGet[string](c, "name"); v != "sraph" {
+		t.Fatalf("name: %v", v)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expect MustGet panics")
+		}
+	}()
+	MustGet[int](c, "missing")
+}
@@ -0,0 +1,266 @@
+package env
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sraphs/config"
+)
+
+var (
+	_ config.SourceContext = (*dotenv)(nil)
+	_ config.LoggerSetter  = (*dotenv)(nil)
+)
+
+type dotenv struct {
+	dir     string
+	profile string
+	setenv  bool
+	logger  config.Logger
+}
+
+// DotenvOption is dotenv source option.
+type DotenvOption func(*dotenv)
+
+// WithSetenv with setting the loaded variables which are
+// not set in the process environment by os.Setenv.
+func WithSetenv() DotenvOption {
+	return func(s *dotenv) {
+		s.setenv = true
+	}
+}
+
+// NewDotenvSource new a source of the dotenv files in dir, which are
+// .env, .env.local, .env.<profile> and .env.<profile>.local, later files
+// take precedence and missing files are skipped. Lines may start with
+// export, values may be quoted, and ${VAR} is interpolated from the
+// variables defined before, then from the process environment.
+func NewDotenvSource(dir, profile string, opts ...DotenvOption) config.Source {
+	s := &dotenv{dir: dir, profile: profile, logger: config.NopLogger}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+func (s *dotenv) String() string {
+	return "dotenv:" + s.dir
+}
+
+func (s *dotenv) SetLogger(l config.Logger) {
+	s.logger = l
+}
+
+func (s *dotenv) files() []string {
+	files := []string{".env", ".env.local"}
+	if s.profile != "" {
+		files = append(files, ".env."+s.profile, ".env."+s.profile+".local")
+	}
+	return files
+}
+
+func (s *dotenv) Load() ([]*config.Descriptor, error) {
+	var (
+		descs []*config.Descriptor
+		vars  = make(map[string]string)
+		keys  []string
+	)
+	for _, name := range s.files() {
+		data, err := os.ReadFile(filepath.Join(s.dir, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		parsed, err := parseDotenv(string(data), func(key string) (string, bool) {
+			if v, ok := vars[key]; ok {
+				return v, true
+			}
+			return os.LookupEnv(key)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		values := make(map[string]string, len(parsed))
+		for _, kv := range parsed {
+			if _, ok := vars[kv[0]]; !ok {
+				keys = append(keys, kv[0])
+			}
+			vars[kv[0]] = kv[1]
+			values[config.NormalizeKey(kv[0], "_")] = kv[1]
+		}
+		// json keeps the values verbatim, the env codec would parse them again
+		data, err = json.Marshal(values)
+		if err != nil {
+			return nil, err
+		}
+		s.logger.Debug("load dotenv file", "name", name, "vars", len(values))
+		descs = append(descs, &config.Descriptor{Name: name, Format: "json", Data: data, Normalized: true})
+	}
+
+	if s.setenv {
+		for _, key := range keys {
+			if _, ok := os.LookupEnv(key); ok {
+				continue
+			}
+			if err := os.Setenv(key, vars[key]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return descs, nil
+}
+
+func (s *dotenv) LoadContext(ctx context.Context) ([]*config.Descriptor, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.Load()
+}
+
+func (s *dotenv) Watch() (config.Watcher, error) {
+	return nil, nil
+}
+
+func (s *dotenv) WatchContext(ctx context.Context) (config.Watcher, error) {
+	return nil, nil
+}
+
+// parseDotenv parses the key value pairs of a dotenv file in order,
+// lookup resolves the variables of ${VAR} interpolations.
+func parseDotenv(data string, lookup func(string) (string, bool)) ([][2]string, error) {
+	var (
+		pairs [][2]string
+		vars  = make(map[string]string)
+	)
+	local := func(key string) (string, bool) {
+		if v, ok := vars[key]; ok {
+			return v, true
+		}
+		return lookup(key)
+	}
+
+	lines := strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("line %d: invalid line %q", i+1, lines[i])
+		}
+		value = strings.TrimSpace(value)
+
+		switch {
+		case strings.HasPrefix(value, "'"), strings.HasPrefix(value, `"`):
+			quote := value[:1]
+			value = value[1:]
+			// quoted values may span lines
+			for !hasClosingQuote(value, quote) && i+1 < len(lines) {
+				i++
+				value += "\n" + lines[i]
+			}
+			end := closingQuote(value, quote)
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated quoted value", i+1)
+			}
+			value = value[:end]
+			if quote == `"` {
+				value = expand(unescape(value), local)
+			}
+		default:
+			if j := strings.Index(value, " #"); j >= 0 {
+				value = strings.TrimSpace(value[:j])
+			}
+			value = expand(value, local)
+		}
+		vars[key] = value
+		pairs = append(pairs, [2]string{key, value})
+	}
+	return pairs, nil
+}
+
+func hasClosingQuote(s, quote string) bool {
+	return closingQuote(s, quote) >= 0
+}
+
+// closingQuote returns the index of the closing quote,
+// a double quote may be escaped by a backslash.
+func closingQuote(s, quote string) int {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote == `"` && s[i] == '\\':
+			i++
+		case s[i] == quote[0]:
+			return i
+		}
+	}
+	return -1
+}
+
+var dotenvEscaper = strings.NewReplacer(`\n`, "\n", `\r`, "\r", `\t`, "\t", `\"`, `"`, `\\`, `\`)
+
+func unescape(s string) string {
+	// keep \$ escaped for expand
+	parts := strings.Split(s, `\$`)
+	for i, p := range parts {
+		parts[i] = dotenvEscaper.Replace(p)
+	}
+	return strings.Join(parts, `\$`)
+}
+
+// expand interpolates ${VAR}, ${VAR:-default} and $VAR, \$ is a literal $.
+func expand(s string, lookup func(string) (string, bool)) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == '$':
+			b.WriteByte('$')
+			i++
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				b.WriteString(s[i:])
+				return b.String()
+			}
+			name, def, hasDef := strings.Cut(s[i+2:i+end], ":-")
+			if v, ok := lookup(name); ok && (v != "" || !hasDef) {
+				b.WriteString(v)
+			} else {
+				b.WriteString(def)
+			}
+			i += end
+		case s[i] == '$':
+			j := i + 1
+			for j < len(s) && isNameByte(s[j]) {
+				j++
+			}
+			if j == i+1 {
+				b.WriteByte('$')
+				continue
+			}
+			v, _ := lookup(s[i+1 : j])
+			b.WriteString(v)
+			i = j - 1
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
@@ -0,0 +1,107 @@
+package env
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sraphs/config"
+)
+
+func TestParseDotenv(t *testing.T) {
+	lookup := func(key string) (string, bool) {
+		v, ok := map[string]string{"HOME": "/home/app", "EMPTY": ""}[key]
+		return v, ok
+	}
+	data := `
+# comment
+export NAME=app
+PLAIN = value # comment
+HASH=a#b
+SINGLE='${HOME} \n $NAME'
+DOUBLE="${HOME}/data\tx \"q\" \$HOME"
+MULTI="line1
+line2"
+REF=${NAME}-$NAME-${MISSING:-def}-${EMPTY:-def}-${EMPTY}
+DOLLAR=$ 5
+`
+	pairs, err := parseDotenv(data, lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][2]string{
+		{"NAME", "app"},
+		{"PLAIN", "value"},
+		{"HASH", "a#b"},
+		{"SINGLE", `${HOME} \n $NAME`},
+		{"DOUBLE", "/home/app/data\tx \"q\" $HOME"},
+		{"MULTI", "line1\nline2"},
+		{"REF", "app-app-def-def-"},
+		{"DOLLAR", "$ 5"},
+	}
+	if !reflect.DeepEqual(pairs, want) {
+		t.Fatalf("expect %q, got %q", want, pairs)
+	}
+
+	for _, bad := range []string{"NOVALUE", `KEY="unterminated`, "BAD KEY=1"} {
+		if _, err := parseDotenv(bad, lookup); err == nil {
+			t.Errorf("expect error for %q", bad)
+		}
+	}
+}
+
+func TestDotenvSource(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		".env":            "DB_HOST=localhost\nDB_PORT=5432\nDB_URL=postgres://${DB_HOST}:${DB_PORT}\nDOTENV_TEST_NAME=base\n",
+		".env.local":      "DB_PORT=5433\n",
+		".env.prod":       "DB_HOST=db.prod\nLEVEL=warn\n",
+		".env.prod.local": "LEVEL=debug\nURL=${DB_URL}/app\n",
+		".env.dev":        "LEVEL=dev\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ds, err := NewDotenvSource(dir, "").Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds) != 2 || ds[0].Name != ".env" || ds[1].Name != ".env.local" {
+		t.Fatalf("unexpected descriptors %+v", ds)
+	}
+
+	t.Setenv("DOTENV_TEST_NAME", "process")
+	os.Unsetenv("DB_HOST")
+	c := config.New(config.WithSource(NewDotenvSource(dir, "prod", WithSetenv())))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	t.Cleanup(func() {
+		for _, key := range []string{"DB_HOST", "DB_PORT", "DB_URL", "LEVEL", "URL"} {
+			os.Unsetenv(key)
+		}
+	})
+
+	for key, want := range map[string]string{
+		"db.host": "db.prod",
+		"db.port": "5433",
+		"db.url":  "postgres://localhost:5432",
+		"level":   "debug",
+		"url":     "postgres://localhost:5432/app",
+	} {
+		if got, _ := c.Get(key).String(); got != want {
+			t.Errorf("%s: expect %q, got %q", key, want, got)
+		}
+	}
+
+	if got := os.Getenv("DB_HOST"); got != "db.prod" {
+		t.Errorf("expect DB_HOST set to db.prod, got %q", got)
+	}
+	if got := os.Getenv("DOTENV_TEST_NAME"); got != "process" {
+		t.Errorf("expect process environment kept, got %q", got)
+	}
+}
@@ -1,44 +1,306 @@
 package env
 
 import (
-	"bytes"
+	"context"
+	"encoding/json"
 	"os"
+	"path"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/sraphs/config"
+	"github.com/sraphs/config/internal/secret"
 )
 
-var _ config.Source = (*env)(nil)
+var (
+	_ config.SourceContext = (*env)(nil)
+	_ config.LoggerSetter  = (*env)(nil)
+)
 
 type env struct {
-	prefix string
+	prefixes      []string
+	insensitive   bool
+	allow         []string
+	fileSuffix    string
+	deny          []string
+	separator     string
+	listSeparator string
+	fields        map[string]field
+	mapper        func(name string) string
+	interval      time.Duration
+	signals       []os.Signal
+	logger        config.Logger
 }
 
-func NewSource(prefix string) config.Source {
-	return &env{prefix: prefix}
+// NewSource new an env source of the variables with prefix, which matches
+// only if followed by "_", like APP in APP_X but not in APPLE_X. An empty
+// prefix matches every variable, including PATH and HOME, as it always
+// did, use WithAllow or WithDeny to choose the variables to load.
+func NewSource(prefix string, opts ...Option) config.Source {
+	s := &env{prefixes: []string{prefix}, separator: "_", listSeparator: ",", logger: config.NopLogger}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+func (s *env) String() string {
+	return "env:" + strings.Join(s.prefixes, ",")
+}
+
+func (s *env) SetLogger(l config.Logger) {
+	s.logger = l
 }
 
 func (s *env) Load() ([]*config.Descriptor, error) {
-	var buf bytes.Buffer
+	vars, _, err := s.environ()
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Debug("load environ", "prefixes", s.prefixes, "vars", len(vars))
+	d, err := s.descriptor(vars)
+	if err != nil {
+		return nil, err
+	}
+	return []*config.Descriptor{d}, nil
+}
+
+func (s *env) LoadContext(ctx context.Context) ([]*config.Descriptor, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.Load()
+}
+
+// Watch returns no watcher unless a poll interval or signals are set.
+func (s *env) Watch() (config.Watcher, error) {
+	return s.WatchContext(context.Background())
+}
+
+func (s *env) WatchContext(ctx context.Context) (config.Watcher, error) {
+	vars, files, err := s.environ()
+	if err != nil {
+		return nil, err
+	}
+	if s.interval <= 0 && len(s.signals) == 0 && len(files) == 0 {
+		return nil, nil
+	}
+	return newWatcher(ctx, s, vars, files)
+}
 
-	environ := os.Environ()
+// environ returns the filtered environment by name without prefix, for
+// a name matching several prefixes the longest prefix is trimmed, and
+// for names equal without prefix the later prefix takes precedence.
+// Variables with the file suffix are read from the file they name, and
+// take precedence over the variable without suffix, files are the paths.
+func (s *env) environ() (vars map[string]string, files []string, err error) {
+	vars = make(map[string]string)
+	ranks := make(map[string]int)
+	paths := make(map[string]string)
+	pathRanks := make(map[string]int)
+	for _, line := range os.Environ() {
+		name, value, _ := strings.Cut(line, "=")
+		if name == "" || !s.allowed(name) {
+			continue
+		}
+		key, rank, ok := s.trimPrefix(name)
+		if !ok {
+			continue
+		}
+		if k, ok := s.trimFileSuffix(key); ok {
+			if r, ok := pathRanks[k]; !ok || r <= rank {
+				paths[k], pathRanks[k] = value, rank
+			}
+			continue
+		}
+		if r, ok := ranks[key]; ok && r > rank {
+			continue
+		}
+		vars[key], ranks[key] = value, rank
+	}
 
-	for _, line := range environ {
-		if strings.HasPrefix(line, s.prefix) {
-			line = strings.TrimPrefix(line, s.prefix)
-			line = strings.TrimPrefix(line, "_")
-			buf.WriteString(line)
-			buf.WriteString("\n")
+	keys := make([]string, 0, len(paths))
+	for key := range paths {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, err := secret.ReadFile(paths[key])
+		if err != nil {
+			return nil, nil, err
 		}
+		vars[key] = value
+		files = append(files, paths[key])
+	}
+	return vars, files, nil
+}
+
+// trimFileSuffix returns key without the file suffix, if it has it.
+func (s *env) trimFileSuffix(key string) (string, bool) {
+	n := len(key) - len(s.fileSuffix)
+	if s.fileSuffix == "" || n <= 0 || !s.equal(key[n:], s.fileSuffix) {
+		return "", false
 	}
+	return key[:n], true
+}
 
-	return []*config.Descriptor{{
-		Name:   "environ",
-		Format: "env",
-		Data:   buf.Bytes(),
-	}}, nil
+// trimPrefix returns name without the longest matching prefix and its
+// index, a prefix matches only if followed by "_", like APP in APP_X
+// but not in APPLE_X, unless it ends with "_" itself.
+func (s *env) trimPrefix(name string) (string, int, bool) {
+	var (
+		key  string
+		rank = -1
+		size = -1
+	)
+	for i, prefix := range s.prefixes {
+		if len(prefix) < size || len(name) < len(prefix) || !s.equal(name[:len(prefix)], prefix) {
+			continue
+		}
+		rest := name[len(prefix):]
+		if prefix != "" && !strings.HasSuffix(prefix, "_") {
+			if !strings.HasPrefix(rest, "_") {
+				continue
+			}
+			rest = rest[1:]
+		}
+		if rest == "" {
+			continue
+		}
+		key, rank, size = rest, i, len(prefix)
+	}
+	return key, rank, rank >= 0
 }
 
-func (s *env) Watch() (config.Watcher, error) {
-	return nil, nil
+// allowed reports whether a variable name passes the allow and deny lists.
+func (s *env) allowed(name string) bool {
+	if len(s.allow) > 0 && !s.match(s.allow, name) {
+		return false
+	}
+	return !s.match(s.deny, name)
+}
+
+// match reports whether name matches one of the names or path.Match patterns.
+func (s *env) match(patterns []string, name string) bool {
+	if s.insensitive {
+		name = strings.ToUpper(name)
+	}
+	for _, pattern := range patterns {
+		if s.insensitive {
+			pattern = strings.ToUpper(pattern)
+		}
+		if ok, _ := path.Match(pattern, name); ok || pattern == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *env) equal(a, b string) bool {
+	if s.insensitive {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// descriptor maps the variables to config keys and values, it is json
+// encoded as values may be lists and maps, and keys may contain dots.
+func (s *env) descriptor(vars map[string]string) (*config.Descriptor, error) {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	values := make(map[string]interface{}, len(vars))
+	for _, name := range names {
+		key := s.mapKey(name)
+		if key == "" {
+			continue
+		}
+		values[key] = s.mapValue(key, vars[name])
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config.Descriptor{
+		Name:       "environ",
+		Format:     "json",
+		Data:       data,
+		Normalized: true,
+	}, nil
+}
+
+// mapKey returns the config key of a variable name without prefix,
+// by the key mapper, or split by the separator and matched against the
+// field paths of the target, empty if the variable is skipped.
+func (s *env) mapKey(name string) string {
+	if s.mapper != nil {
+		return s.mapper(name)
+	}
+	key := config.NormalizeKey(name, s.separator)
+	if f, ok := s.fields[foldPath(key)]; ok {
+		return f.path
+	}
+	return key
+}
+
+// mapValue returns the value of a key, a list or a map if the field of the
+// target is, or if the value is json encoded.
+func (s *env) mapValue(key, value string) interface{} {
+	f, ok := s.fields[foldPath(key)]
+	if !ok {
+		if v, ok := decodeJSON(value); ok {
+			return v
+		}
+		return value
+	}
+	switch f.kind {
+	case listField:
+		if v, ok := decodeJSON(value); ok {
+			if list, ok := v.([]interface{}); ok {
+				return list
+			}
+		}
+		list := []interface{}{}
+		if value == "" {
+			return list
+		}
+		for _, item := range strings.Split(value, s.listSeparator) {
+			list = append(list, strings.TrimSpace(item))
+		}
+		return list
+	case mapField:
+		if v, ok := decodeJSON(value); ok {
+			if m, ok := v.(map[string]interface{}); ok {
+				return m
+			}
+		}
+		m := map[string]interface{}{}
+		if value == "" {
+			return m
+		}
+		for _, item := range strings.Split(value, s.listSeparator) {
+			k, v, _ := strings.Cut(item, "=")
+			m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+		return m
+	}
+	return value
+}
+
+// decodeJSON decodes a json encoded list or map.
+func decodeJSON(value string) (interface{}, bool) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "[") && !strings.HasPrefix(value, "{") {
+		return nil, false
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(value), &v); err != nil {
+		return nil, false
+	}
+	return v, true
 }
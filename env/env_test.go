@@ -1,13 +1,23 @@
 package env
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"reflect"
+	"runtime"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/sraphs/maps"
 
 	"github.com/sraphs/config"
+	"github.com/sraphs/config/internal/testdata"
 )
 
 func TestEnvWithPrefix(t *testing.T) {
@@ -125,3 +135,405 @@ func TestEnvWithoutPrefix(t *testing.T) {
 		})
 	}
 }
+
+func TestEnvScanRepeatable(t *testing.T) {
+	os.Setenv("sraph_repeat_name", "sraph_app")
+	os.Setenv("sraph_repeat_age", "20")
+	os.Setenv("sraph_repeat_debug", "true")
+
+	c := config.New(
+		config.WithSource(NewSource("sraph_")),
+	)
+
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	type repeat struct {
+		Name  string `json:"name"`
+		Age   int    `json:"age"`
+		Debug bool   `json:"debug"`
+	}
+
+	for i := 0; i < 2; i++ {
+		var conf struct {
+			Repeat repeat `json:"repeat"`
+		}
+		if err := c.Scan(&conf); err != nil {
+			t.Fatal(err)
+		}
+		expect := repeat{Name: "sraph_app", Age: 20, Debug: true}
+		if !reflect.DeepEqual(expect, conf.Repeat) {
+			t.Fatalf("scan %d: expect %+v, actual %+v", i, expect, conf.Repeat)
+		}
+	}
+
+	if age, err := c.Get("repeat.age").Int(); err != nil || age != 20 {
+		t.Fatalf("expect 20, actual %v %v", age, err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	if w, err := NewSource("WATCH_TEST_").Watch(); w != nil || err != nil {
+		t.Fatalf("expect no watcher, got %v %v", w, err)
+	}
+
+	t.Setenv("WATCH_TEST_LEVEL", "info")
+	w, err := NewSource("WATCH_TEST_", WithPollInterval(10*time.Millisecond)).Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	// no descriptor without a change
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := w.(config.WatcherContext).NextContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect no change, got %v", err)
+	}
+
+	os.Setenv("WATCH_TEST_LEVEL", "debug")
+	os.Setenv("OTHER_LEVEL", "error")
+	defer os.Unsetenv("OTHER_LEVEL")
+	ds, err := w.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds) != 1 || ds[0].Name != "environ" || string(ds[0].Data) != `{"level":"debug"}` {
+		t.Fatalf("unexpected descriptors %+v", ds)
+	}
+}
+
+func TestWatchSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no SIGHUP on windows")
+	}
+	t.Setenv("SIGNAL_TEST_LEVEL", "info")
+
+	c := config.New(config.WithSource(NewSource("SIGNAL_TEST_", WithSignal())))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	changed := make(chan struct{}, 1)
+	if err := c.Watch(func(config.Config) { changed <- struct{}{} }); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("SIGNAL_TEST_LEVEL", "warn")
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Signal(syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("config is not reloaded")
+	}
+	if s, _ := c.Get("level").String(); s != "warn" {
+		t.Fatalf("expect warn, got %s", s)
+	}
+}
+
+func TestKeyMapping(t *testing.T) {
+	type server struct {
+		HTTPAddr    string            `json:"http_addr"`
+		ReadTimeout time.Duration     `json:"read_timeout"`
+		Hosts       []string          `json:"hosts"`
+		Ports       []int             `json:"ports"`
+		Labels      map[string]string `json:"labels"`
+		Weights     map[string]int    `config:"weights"`
+	}
+	type conf struct {
+		Server server `json:"server"`
+		Debug  bool
+	}
+	for k, v := range map[string]string{
+		"MAP_TEST_SERVER_HTTP_ADDR":    ":8080",
+		"MAP_TEST_SERVER_READ_TIMEOUT": "2s",
+		"MAP_TEST_SERVER_HOSTS":        "a, b,c",
+		"MAP_TEST_SERVER_PORTS":        "[80, 443]",
+		"MAP_TEST_SERVER_LABELS":       "env=prod,tier=web",
+		"MAP_TEST_SERVER_WEIGHTS":      `{"a": 1, "b": 2}`,
+		"MAP_TEST_DEBUG":               "true",
+	} {
+		t.Setenv(k, v)
+	}
+
+	t.Run("target", func(t *testing.T) {
+		c := config.New(config.WithSource(NewSource("MAP_TEST_", WithTarget(&conf{}))))
+		if err := c.Load(); err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		var got conf
+		if err := c.Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		want := conf{
+			Server: server{
+				HTTPAddr:    ":8080",
+				ReadTimeout: 2 * time.Second,
+				Hosts:       []string{"a", "b", "c"},
+				Ports:       []int{80, 443},
+				Labels:      map[string]string{"env": "prod", "tier": "web"},
+				Weights:     map[string]int{"a": 1, "b": 2},
+			},
+			Debug: true,
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("expect %+v, got %+v", want, got)
+		}
+	})
+
+	t.Run("proto target", func(t *testing.T) {
+		t.Setenv("PROTO_TEST_DATA_REDIS_READ_TIMEOUT", "2s")
+		t.Setenv("PROTO_TEST_SERVER_HTTP_ADDR", ":8000")
+		c := config.New(config.WithSource(NewSource("PROTO_TEST_", WithTarget(&testdata.Conf{}))))
+		if err := c.Load(); err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		var got testdata.Conf
+		if err := c.Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Data.Redis.ReadTimeout.AsDuration() != 2*time.Second || got.Server.Http.Addr != ":8000" {
+			t.Fatalf("unexpected config %v", &got)
+		}
+	})
+
+	t.Run("separator", func(t *testing.T) {
+		t.Setenv("SEP_TEST_SERVER__HTTP_ADDR", ":9090")
+		ds, err := NewSource("SEP_TEST_", WithSeparator("__")).Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(ds[0].Data) != `{"server.http_addr":":9090"}` {
+			t.Fatalf("unexpected data %s", ds[0].Data)
+		}
+	})
+
+	t.Run("key mapper", func(t *testing.T) {
+		ds, err := NewSource("MAP_TEST_", WithKeyMapper(func(name string) string {
+			if name != "DEBUG" {
+				return ""
+			}
+			return "app.debug"
+		})).Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(ds[0].Data) != `{"app.debug":"true"}` {
+			t.Fatalf("unexpected data %s", ds[0].Data)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		ds, err := NewSource("MAP_TEST_SERVER_").Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var values map[string]interface{}
+		if err := json.Unmarshal(ds[0].Data, &values); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(values["ports"], []interface{}{float64(80), float64(443)}) || values["hosts"] != "a, b,c" {
+			t.Fatalf("unexpected values %v", values)
+		}
+	})
+}
+
+func TestPrefixMatching(t *testing.T) {
+	for k, v := range map[string]string{
+		"APP_NAME":      "app",
+		"APP_DB_HOST":   "localhost",
+		"APP_DB_PASS":   "secret",
+		"APPLE_COLOR":   "red",
+		"APP":           "bare",
+		"SVC_NAME":      "svc",
+		"SVC_PORT":      "80",
+		"app_lower_key": "lower",
+	} {
+		t.Setenv(k, v)
+	}
+	load := func(t *testing.T, prefix string, opts ...Option) map[string]interface{} {
+		t.Helper()
+		ds, err := NewSource(prefix, opts...).Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		values := make(map[string]interface{})
+		if err := json.Unmarshal(ds[0].Data, &values); err != nil {
+			t.Fatal(err)
+		}
+		return values
+	}
+
+	tests := []struct {
+		name   string
+		prefix string
+		opts   []Option
+		expect map[string]interface{}
+	}{
+		{
+			name:   "strict",
+			prefix: "APP",
+			expect: map[string]interface{}{"name": "app", "db.host": "localhost", "db.pass": "secret"},
+		},
+		{
+			name:   "separator in prefix",
+			prefix: "APP_DB_",
+			expect: map[string]interface{}{"host": "localhost", "pass": "secret"},
+		},
+		{
+			name:   "case insensitive",
+			prefix: "app",
+			opts:   []Option{WithCaseInsensitive(), WithDeny("app_db_*")},
+			expect: map[string]interface{}{"name": "app", "lower.key": "lower"},
+		},
+		{
+			name:   "multiple prefixes",
+			prefix: "APP",
+			opts:   []Option{WithPrefix("SVC"), WithDeny("APP_DB_*")},
+			expect: map[string]interface{}{"name": "svc", "port": "80"},
+		},
+		{
+			name:   "allow",
+			prefix: "",
+			opts:   []Option{WithAllow("APP_NAME", "SVC_*"), WithDeny("SVC_PORT")},
+			expect: map[string]interface{}{"app.name": "app", "svc.name": "svc"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := load(t, test.prefix, test.opts...); !reflect.DeepEqual(got, test.expect) {
+				t.Errorf("expect %v, got %v", test.expect, got)
+			}
+		})
+	}
+
+	// an empty prefix deliberately loads the whole environment
+	all := load(t, "")
+	if all["path"] != os.Getenv("PATH") || all["apple.color"] != "red" || all["app.name"] != "app" {
+		t.Errorf("expect the whole environment, got %v", all)
+	}
+}
+
+func TestFileSuffix(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "db_password")
+	if err := os.WriteFile(secret, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SECRET_TEST_DB_USER", "app")
+	t.Setenv("SECRET_TEST_DB_PASSWORD", "plain")
+	t.Setenv("SECRET_TEST_DB_PASSWORD_FILE", secret)
+
+	ds, err := NewSource("SECRET_TEST_").Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(ds[0].Data), `"db.password.file":"`) {
+		t.Fatalf("expect no file indirection by default, got %s", ds[0].Data)
+	}
+
+	c := config.New(config.WithSource(NewSource("SECRET_TEST_", WithFileSuffix("_FILE"))))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if s, _ := c.Get("db.password").String(); s != "s3cret" {
+		t.Fatalf("expect s3cret, got %q", s)
+	}
+	if s, _ := c.Get("db.user").String(); s != "app" {
+		t.Fatalf("expect app, got %q", s)
+	}
+
+	changed := make(chan struct{}, 4)
+	if err := c.WatchKey("db.password", func(_, _ config.Value) { changed <- struct{}{} }); err != nil {
+		t.Fatal(err)
+	}
+	tmp := secret + ".tmp"
+	if err := os.WriteFile(tmp, []byte("rotated\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, secret); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("secret is not re-read")
+	}
+	if s, _ := c.Get("db.password").String(); s != "rotated" {
+		t.Fatalf("expect rotated, got %q", s)
+	}
+
+	t.Setenv("SECRET_TEST_DB_PASSWORD_FILE", filepath.Join(dir, "missing"))
+	if _, err := NewSource("SECRET_TEST_", WithFileSuffix("_FILE")).Load(); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expect ErrNotExist, got %v", err)
+	}
+}
+
+func TestFileSuffixAddedLater(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no SIGHUP on windows")
+	}
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "token")
+	if err := os.WriteFile(secret, []byte("first\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LATE_SECRET_TEST_NAME", "app")
+
+	c := config.New(config.WithSource(NewSource("LATE_SECRET_TEST_",
+		WithFileSuffix("_FILE"), WithSignal())))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	changed := make(chan string, 4)
+	if err := c.WatchKey("token", func(_, v config.Value) {
+		s, _ := v.String()
+		changed <- s
+	}); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LATE_SECRET_TEST_TOKEN_FILE", secret)
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Signal(syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case s := <-changed:
+		if s != "first" {
+			t.Fatalf("expect first, got %q", s)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("config is not reloaded")
+	}
+
+	tmp := secret + ".tmp"
+	if err := os.WriteFile(tmp, []byte("rotated\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, secret); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case s := <-changed:
+		if s != "rotated" {
+			t.Fatalf("expect rotated, got %q", s)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("secret of a later variable is not re-read")
+	}
+}
@@ -0,0 +1,102 @@
+package env
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// Option is env source option.
+type Option func(*env)
+
+// WithPollInterval with the interval the watcher re-reads the environment at.
+func WithPollInterval(d time.Duration) Option {
+	return func(s *env) {
+		s.interval = d
+	}
+}
+
+// WithSignal with the signals on which the watcher re-reads
+// the environment, SIGHUP if none is given.
+func WithSignal(sig ...os.Signal) Option {
+	return func(s *env) {
+		if len(sig) == 0 {
+			sig = []os.Signal{syscall.SIGHUP}
+		}
+		s.signals = append(s.signals, sig...)
+	}
+}
+
+// WithSeparator with the separator of nested keys in variable names,
+// "_" by default, "__" keeps single underscores in key segments.
+func WithSeparator(sep string) Option {
+	return func(s *env) {
+		s.separator = sep
+	}
+}
+
+// WithListSeparator with the separator of list items and map entries in
+// values of list and map fields of the target, "," by default.
+func WithListSeparator(sep string) Option {
+	return func(s *env) {
+		s.listSeparator = sep
+	}
+}
+
+// WithTarget with the struct or proto message the config is scanned into,
+// variable names are matched against its field paths case-insensitively,
+// ignoring separators, and values of list and map fields are split.
+func WithTarget(v interface{}) Option {
+	return func(s *env) {
+		s.fields = fieldPaths(v)
+	}
+}
+
+// WithKeyMapper with a func mapping a variable name without prefix to a
+// config key like "server.http.addr", an empty key skips the variable.
+func WithKeyMapper(fn func(name string) string) Option {
+	return func(s *env) {
+		s.mapper = fn
+	}
+}
+
+// WithPrefix with more prefixes of the variables to load, for variables
+// equal without prefix the later prefix takes precedence.
+func WithPrefix(prefixes ...string) Option {
+	return func(s *env) {
+		s.prefixes = append(s.prefixes, prefixes...)
+	}
+}
+
+// WithCaseInsensitive with matching prefixes and the allow
+// and deny lists against variable names case-insensitively.
+func WithCaseInsensitive() Option {
+	return func(s *env) {
+		s.insensitive = true
+	}
+}
+
+// WithAllow with the names of the only variables to load, including
+// their prefix, names may be path.Match patterns like "APP_DB_*".
+func WithAllow(names ...string) Option {
+	return func(s *env) {
+		s.allow = append(s.allow, names...)
+	}
+}
+
+// WithDeny with the names of variables not to load, including their
+// prefix, names may be path.Match patterns, deny takes precedence.
+func WithDeny(names ...string) Option {
+	return func(s *env) {
+		s.deny = append(s.deny, names...)
+	}
+}
+
+// WithFileSuffix with the suffix of variables naming a file to read the
+// value from, like "_FILE" for DB_PASSWORD_FILE=/run/secrets/db, the
+// trailing newlines are trimmed and the files are watched for rotation.
+func WithFileSuffix(suffix string) Option {
+	return func(s *env) {
+		s.fileSuffix = suffix
+	}
+}
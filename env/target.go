@@ -0,0 +1,145 @@
+package env
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type fieldKind int
+
+const (
+	scalarField fieldKind = iota
+	listField
+	mapField
+)
+
+type field struct {
+	path string
+	kind fieldKind
+}
+
+// foldPath returns the form of a key matched against field paths,
+// "server.http_addr", "server.http.addr" and "serverHTTPAddr" are equal.
+func foldPath(key string) string {
+	return strings.ToLower(strings.NewReplacer(".", "", "_", "", "-", "").Replace(key))
+}
+
+// fieldPaths returns the fields of the struct or proto message v by folded path.
+func fieldPaths(v interface{}) map[string]field {
+	fields := make(map[string]field)
+	if m, ok := v.(proto.Message); ok {
+		protoPaths(fields, "", m.ProtoReflect().Descriptor(), 0)
+		return fields
+	}
+	structPaths(fields, "", reflect.TypeOf(v), 0)
+	return fields
+}
+
+// maxDepth stops recursive types.
+const maxDepth = 16
+
+func addField(fields map[string]field, path string, kind fieldKind) {
+	if _, ok := fields[foldPath(path)]; !ok {
+		fields[foldPath(path)] = field{path: path, kind: kind}
+	}
+}
+
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+func structPaths(fields map[string]field, prefix string, t reflect.Type, depth int) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || depth > maxDepth {
+		return
+	}
+	if m, ok := reflect.New(t).Interface().(proto.Message); ok {
+		protoPaths(fields, prefix, m.ProtoReflect().Descriptor(), depth)
+		return
+	}
+
+	switch {
+	case t == durationType || t == timeType:
+		addField(fields, prefix, scalarField)
+		return
+	case t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8, t.Kind() == reflect.Array:
+		addField(fields, prefix, listField)
+		return
+	case t.Kind() == reflect.Map:
+		addField(fields, prefix, mapField)
+		return
+	case t.Kind() != reflect.Struct:
+		if prefix != "" {
+			addField(fields, prefix, scalarField)
+		}
+		return
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, ok := fieldName(sf)
+		if !ok {
+			continue
+		}
+		if sf.Anonymous && name == "" {
+			structPaths(fields, prefix, sf.Type, depth+1)
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		structPaths(fields, joinPath(prefix, name), sf.Type, depth+1)
+	}
+}
+
+// fieldName returns the name of a field by its config or json tag,
+// false if the field is skipped.
+func fieldName(sf reflect.StructField) (string, bool) {
+	for _, tag := range []string{"config", "json"} {
+		name, _, _ := strings.Cut(sf.Tag.Get(tag), ",")
+		if name == "-" {
+			return "", false
+		}
+		if name != "" {
+			return name, true
+		}
+	}
+	return "", true
+}
+
+func protoPaths(fields map[string]field, prefix string, md protoreflect.MessageDescriptor, depth int) {
+	if depth > maxDepth {
+		return
+	}
+	for i := 0; i < md.Fields().Len(); i++ {
+		fd := md.Fields().Get(i)
+		path := joinPath(prefix, string(fd.Name()))
+		switch {
+		case fd.IsList():
+			addField(fields, path, listField)
+		case fd.IsMap():
+			addField(fields, path, mapField)
+		case fd.Message() != nil && !strings.HasPrefix(string(fd.Message().FullName()), "google.protobuf."):
+			protoPaths(fields, path, fd.Message(), depth+1)
+		default:
+			addField(fields, path, scalarField)
+		}
+	}
+}
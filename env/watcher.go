@@ -0,0 +1,141 @@
+package env
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/sraphs/config"
+	"github.com/sraphs/config/internal/secret"
+)
+
+// watcher re-reads the environment on signals, at the poll interval or
+// when a file read by a variable with the file suffix changes, and returns
+// a change only if the prefix filtered set differs.
+type watcher struct {
+	s    *env
+	vars map[string]string
+
+	ticker  *time.Ticker
+	tick    <-chan time.Time
+	signals chan os.Signal
+
+	// mu guards sw, created once a variable with the file suffix is set
+	mu     sync.Mutex
+	sw     *secret.Watcher
+	events <-chan fsnotify.Event
+	errs   <-chan error
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+var _ config.WatcherContext = (*watcher)(nil)
+
+func newWatcher(ctx context.Context, s *env, vars map[string]string, files []string) (config.Watcher, error) {
+	w := &watcher{s: s, vars: vars, signals: make(chan os.Signal, 1)}
+	w.ctx, w.cancel = context.WithCancel(ctx)
+	if err := w.watchFiles(files); err != nil {
+		w.cancel()
+		return nil, err
+	}
+	if s.interval > 0 {
+		w.ticker = time.NewTicker(s.interval)
+		w.tick = w.ticker.C
+	}
+	if len(s.signals) > 0 {
+		signal.Notify(w.signals, s.signals...)
+	}
+	return w, nil
+}
+
+// watchFiles watches the files read by variables with the file suffix,
+// the secret watcher is created by the first of them.
+func (w *watcher) watchFiles(files []string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.sw == nil {
+		if len(files) == 0 || w.ctx.Err() != nil {
+			return nil
+		}
+		sw, err := secret.NewWatcher()
+		if err != nil {
+			return err
+		}
+		w.sw, w.events, w.errs = sw, sw.Events(), sw.Errors()
+	}
+	return w.sw.Watch(files)
+}
+
+func (w *watcher) Next() ([]*config.Descriptor, error) {
+	return w.NextContext(context.Background())
+}
+
+func (w *watcher) NextContext(ctx context.Context) ([]*config.Descriptor, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-w.ctx.Done():
+			return nil, w.ctx.Err()
+		case sig := <-w.signals:
+			w.s.logger.Debug("reload environ", "signal", sig.String())
+		case <-w.tick:
+		case event := <-w.events:
+			w.s.logger.Debug("reload environ", "file", event.Name, "op", event.Op.String())
+			if err := w.sw.Settle(ctx); err != nil {
+				return nil, err
+			}
+		case err := <-w.errs:
+			return nil, err
+		}
+
+		vars, files, err := w.s.environ()
+		if err != nil {
+			return nil, err
+		}
+		if err := w.watchFiles(files); err != nil {
+			return nil, err
+		}
+		if equalVars(w.vars, vars) {
+			continue
+		}
+		w.vars = vars
+		w.s.logger.Debug("environ changed", "prefixes", w.s.prefixes, "vars", len(vars))
+		d, err := w.s.descriptor(vars)
+		if err != nil {
+			return nil, err
+		}
+		return []*config.Descriptor{d}, nil
+	}
+}
+
+func (w *watcher) Stop() error {
+	w.cancel()
+	signal.Stop(w.signals)
+	if w.ticker != nil {
+		w.ticker.Stop()
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.sw != nil {
+		return w.sw.Close()
+	}
+	return nil
+}
+
+func equalVars(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
@@ -18,9 +18,10 @@ func Example() {
 	p := path.Join("internal", "testdata", "yaml")
 
 	c := config.New(
+		// sources registered later take precedence: flag > env > file
 		config.WithSource(
-			env.NewSource("sraph_"),
 			file.NewSource(p),
+			env.NewSource("sraph_"),
 			flag.NewSource(),
 		),
 	)
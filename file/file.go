@@ -1,33 +1,90 @@
 package file
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/sraphs/strslices"
 
 	"github.com/sraphs/config"
 )
 
-var _ config.Source = (*file)(nil)
+// ErrFileTooLarge is returned when a config file exceeds the maximum size.
+var ErrFileTooLarge = errors.New("config file too large")
+
+var (
+	_ config.SourceContext = (*file)(nil)
+	_ config.LoggerSetter  = (*file)(nil)
+)
 
 type file struct {
-	path string
+	path      string
+	fsys      fs.FS
+	format    string
+	optional  bool
+	maxSize   int64
+	debounce  time.Duration
+	recursive bool
+	include   []string
+	exclude   []string
+	logger    config.Logger
+}
+
+// NewSource new a file source of a file or a dir, path may start
+// with ~ for the home dir and may be a glob pattern.
+func NewSource(path string, opts ...Option) config.Source {
+	return NewMultiSource([]string{path}, opts...)
+}
+
+func newFile(path string, opts ...Option) *file {
+	f := &file{path: path, debounce: defaultDebounce, logger: config.NopLogger}
+	for _, o := range opts {
+		o(f)
+	}
+	return f
+}
+
+// NewFSSource new a file source of root in fsys, such as defaults embedded
+// with go:embed, root is a slash separated fs.FS path and "." is fsys itself.
+// The source is not watched, as a fs.FS has no change notification.
+func NewFSSource(fsys fs.FS, root string, opts ...Option) config.Source {
+	if root == "" {
+		root = "."
+	}
+	return NewSource(root, append([]Option{WithFS(fsys)}, opts...)...)
 }
 
-// NewSource new a file source.
-func NewSource(path string) config.Source {
-	return &file{path: path}
+func (f *file) String() string {
+	if f.fsys != nil {
+		return "fs:" + f.path
+	}
+	return "file:" + f.path
+}
+
+func (f *file) SetLogger(l config.Logger) {
+	f.logger = l
 }
 
 func (f *file) Load() (desc []*config.Descriptor, err error) {
-	fi, err := os.Stat(f.path)
+	fi, err := f.stat(f.path)
+	if f.optional && errors.Is(err, fs.ErrNotExist) {
+		f.logger.Debug("skip missing optional config file", "path", f.path)
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
 	if fi.IsDir() {
+		f.logger.Debug("load config dir", "path", f.path)
 		return f.loadDir(f.path)
 	}
 	des, err := f.loadFile(f.path)
@@ -37,16 +94,45 @@ func (f *file) Load() (desc []*config.Descriptor, err error) {
 	return []*config.Descriptor{des}, nil
 }
 
+func (f *file) LoadContext(ctx context.Context) ([]*config.Descriptor, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return f.Load()
+}
+
 func (f *file) Watch() (config.Watcher, error) {
-	return newWatcher(f)
+	return f.WatchContext(context.Background())
+}
+
+func (f *file) WatchContext(ctx context.Context) (config.Watcher, error) {
+	if f.fsys != nil {
+		// a fs.FS has no change notification
+		return nil, nil
+	}
+	return newWatcher(ctx, f)
+}
+
+func (f *file) stat(path string) (fs.FileInfo, error) {
+	if f.fsys != nil {
+		return fs.Stat(f.fsys, path)
+	}
+	return os.Stat(path)
+}
+
+func (f *file) open(path string) (fs.File, error) {
+	if f.fsys != nil {
+		return f.fsys.Open(path)
+	}
+	return os.Open(path)
 }
 
 func (f *file) loadFile(path string) (*config.Descriptor, error) {
-	if !isSupported(path) {
+	if !f.isSupported(path) {
 		return nil, config.ErrUnsupportedFormat
 	}
 
-	file, err := os.Open(path)
+	file, err := f.open(path)
 	if err != nil {
 		return nil, err
 	}
@@ -57,38 +143,66 @@ func (f *file) loadFile(path string) (*config.Descriptor, error) {
 		return nil, err
 	}
 
-	data, err := io.ReadAll(file)
+	var r io.Reader = file
+	if f.maxSize > 0 {
+		r = io.LimitReader(file, f.maxSize+1)
+	}
+	data, err := io.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
+	if f.maxSize > 0 && int64(len(data)) > f.maxSize {
+		return nil, fmt.Errorf("%w: %s exceeds %d bytes", ErrFileTooLarge, path, f.maxSize)
+	}
 
 	return &config.Descriptor{
-		Name:   info.Name(),
-		Format: format(info.Name()),
+		Name:   f.name(path, info.Name()),
+		Format: f.formatOf(path),
 		Data:   data,
 	}, nil
 }
 
+// names returns the names of the descriptors Load returns,
+// without reading the files.
+func (f *file) names() map[string]bool {
+	names := make(map[string]bool)
+	fi, err := f.stat(f.path)
+	if err != nil {
+		return names
+	}
+	if !fi.IsDir() {
+		names[f.name(f.path, fi.Name())] = true
+		return names
+	}
+	files, _, err := f.walk(f.path)
+	if err != nil {
+		return names
+	}
+	for _, file := range files {
+		names[f.name(file, filepath.Base(file))] = true
+	}
+	return names
+}
+
+// name returns the slash separated path of a file in a config dir relative
+// to it, so that files of the same name in subdirectories do not collide.
+func (f *file) name(path, base string) string {
+	rel, err := filepath.Rel(f.path, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return base
+	}
+	return filepath.ToSlash(rel)
+}
+
 func (f *file) loadDir(path string) ([]*config.Descriptor, error) {
-	files, err := os.ReadDir(f.path)
+	files, _, err := f.walk(path)
 	if err != nil {
 		return nil, err
 	}
 
 	var descs = make([]*config.Descriptor, 0, len(files))
-
 	for _, file := range files {
-		// ignore hidden files
-		if file.IsDir() || strings.HasPrefix(file.Name(), ".") {
-			continue
-		}
-
-		// ignore files that are not supported formats
-		if !isSupported(file.Name()) {
-			continue
-		}
-
-		desc, err := f.loadFile(filepath.Join(f.path, file.Name()))
+		desc, err := f.loadFile(file)
 		if err != nil {
 			return nil, err
 		}
@@ -98,6 +212,93 @@ func (f *file) loadDir(path string) ([]*config.Descriptor, error) {
 	return descs, nil
 }
 
+// walk returns the config files in dir in lexical order of their relative
+// paths, and the subdirectories walked into when recursive.
+func (f *file) walk(dir string) (files, dirs []string, err error) {
+	fsys, err := f.sub(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	var rels []string
+	err = fs.WalkDir(fsys, ".", func(rel string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if d.IsDir() {
+			// ignore hidden dirs, such as the ..data dir of a ConfigMap
+			if !f.recursive || strings.HasPrefix(d.Name(), ".") {
+				return fs.SkipDir
+			}
+			dirs = append(dirs, f.join(dir, rel))
+			return nil
+		}
+		if !f.match(rel) {
+			f.logger.Debug("skip config file", "name", rel)
+			return nil
+		}
+		rels = append(rels, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(rels)
+	for _, rel := range rels {
+		files = append(files, f.join(dir, rel))
+	}
+	return files, dirs, nil
+}
+
+// sub returns the fs.FS of dir, symlinks of an OS dir are resolved first
+// as os.DirFS does not walk into a symlinked root.
+func (f *file) sub(dir string) (fs.FS, error) {
+	if f.fsys != nil {
+		return fs.Sub(f.fsys, dir)
+	}
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, err
+	}
+	return os.DirFS(root), nil
+}
+
+// join joins dir and the slash separated path rel.
+func (f *file) join(dir, rel string) string {
+	if f.fsys != nil {
+		return path.Join(dir, rel)
+	}
+	return filepath.Join(dir, filepath.FromSlash(rel))
+}
+
+// match reports whether a file of the path relative to the config dir is
+// loaded, hidden and unsupported files are ignored, patterns are matched
+// against both the base name and the relative path.
+func (f *file) match(rel string) bool {
+	rel = filepath.ToSlash(rel)
+	base := path.Base(rel)
+	if strings.HasPrefix(base, ".") || !isSupported(format(base)) {
+		return false
+	}
+	if len(f.include) > 0 && !matchAny(f.include, base, rel) {
+		return false
+	}
+	return !matchAny(f.exclude, base, rel)
+}
+
+func matchAny(patterns []string, names ...string) bool {
+	for _, pattern := range patterns {
+		for _, name := range names {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func format(name string) string {
 	if p := strings.Split(name, "."); len(p) > 1 {
 		return p[len(p)-1]
@@ -105,7 +306,20 @@ func format(name string) string {
 	return ""
 }
 
-func isSupported(path string) bool {
-	format := format(path)
+// formatOf returns the format of the file at path by its extension, the
+// format option only overrides it for the file of the source path itself,
+// the files of a dir are always loaded by their extension.
+func (f *file) formatOf(p string) string {
+	if f.format != "" && filepath.Clean(p) == filepath.Clean(f.path) {
+		return f.format
+	}
+	return format(path.Base(filepath.ToSlash(p)))
+}
+
+func (f *file) isSupported(path string) bool {
+	return isSupported(f.formatOf(path))
+}
+
+func isSupported(format string) bool {
 	return strslices.Contains(config.SupportedFormats, format)
 }
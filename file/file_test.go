@@ -1,10 +1,16 @@
 package file
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/sraphs/maps"
@@ -255,3 +261,681 @@ func testConfig(t *testing.T, c config.Config) {
 		}
 	}
 }
+
+func TestWatchDebounce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.json")
+	if err := os.WriteFile(path, []byte(_testJSON), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewSource(path, WithDebounce(50*time.Millisecond)).Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	// an editor save: write, chmod and write again
+	if err := os.WriteFile(path, []byte(`{"a": 1}`), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(_testJSONUpdate), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ds, err := w.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds) != 1 || string(ds[0].Data) != _testJSONUpdate {
+		t.Fatalf("expect one coalesced change, got %d", len(ds))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err := w.(config.WatcherContext).NextContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect no more changes, got %v", err)
+	}
+}
+
+// writeConfigMap writes files the way the kubelet updates a ConfigMap
+// volume, into a timestamped directory linked by an atomically swapped
+// ..data symlink, which the files link through.
+func writeConfigMap(t *testing.T, dir, version string, files map[string]string) {
+	t.Helper()
+	ts := "..2022_01_01_" + version
+	if err := os.Mkdir(filepath.Join(dir, ts), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, ts, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old, _ := os.Readlink(filepath.Join(dir, "..data"))
+	if err := os.Symlink(ts, filepath.Join(dir, "..data_tmp")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	for name := range files {
+		link := filepath.Join(dir, name)
+		if _, err := os.Lstat(link); err == nil {
+			continue
+		}
+		if err := os.Symlink(filepath.Join("..data", name), link); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if old != "" {
+		if err := os.RemoveAll(filepath.Join(dir, old)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWatchConfigMap(t *testing.T) {
+	for _, debounce := range []time.Duration{0, 50 * time.Millisecond} {
+		t.Run("file/"+debounce.String(), func(t *testing.T) {
+			dir := t.TempDir()
+			writeConfigMap(t, dir, "1", map[string]string{"app.json": `{"v": 1}`})
+
+			w, err := NewSource(filepath.Join(dir, "app.json"), WithDebounce(debounce)).Watch()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer w.Stop()
+
+			writeConfigMap(t, dir, "2", map[string]string{"app.json": `{"v": 2}`})
+			ds, err := w.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ds) != 1 || ds[0].Name != "app.json" || string(ds[0].Data) != `{"v": 2}` {
+				t.Fatalf("unexpected descriptors %+v", ds)
+			}
+
+			writeConfigMap(t, dir, "3", map[string]string{"app.json": `{"v": 3}`})
+			ds, err = w.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ds) != 1 || string(ds[0].Data) != `{"v": 3}` {
+				t.Fatalf("unexpected descriptors %+v", ds)
+			}
+		})
+		t.Run("dir/"+debounce.String(), func(t *testing.T) {
+			dir := t.TempDir()
+			writeConfigMap(t, dir, "1", map[string]string{"a.json": `{"a": 1}`, "b.json": `{"b": 1}`})
+
+			w, err := NewSource(dir, WithDebounce(debounce)).Watch()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer w.Stop()
+
+			writeConfigMap(t, dir, "2", map[string]string{"a.json": `{"a": 2}`, "b.json": `{"b": 2}`})
+			ds, err := w.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]string{}
+			for _, d := range ds {
+				got[d.Name] = string(d.Data)
+			}
+			want := map[string]string{"a.json": `{"a": 2}`, "b.json": `{"b": 2}`}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("expect %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestConfigMapReload(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigMap(t, dir, "1", map[string]string{"app.json": `{"level": "info"}`})
+
+	c := config.New(config.WithSource(NewSource(filepath.Join(dir, "app.json"))))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	reloaded := make(chan struct{}, 1)
+	if err := c.WatchKey("level", func(_, _ config.Value) { reloaded <- struct{}{} }); err != nil {
+		t.Fatal(err)
+	}
+	writeConfigMap(t, dir, "2", map[string]string{"app.json": `{"level": "debug"}`})
+	select {
+	case <-reloaded:
+	case <-time.After(time.Second):
+		t.Fatal("config is not reloaded")
+	}
+	if s, _ := c.Get("level").String(); s != "debug" {
+		t.Fatalf("expect debug, got %s", s)
+	}
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRecursive(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"10-base.yaml":       "level: info\nname: base\n",
+		"20-prod.yaml":       "level: warn\n",
+		"20-prod.local.yaml": "level: debug\n",
+		"30-extra.json":      `{"name": "json"}`,
+		"sub/40-sub.yaml":    "name: sub\n",
+		"sub/app.yaml":       "sub: true\n",
+		".hidden/50.yaml":    "level: error\n",
+		"README.md":          "# conf.d",
+	})
+
+	ds, err := NewSource(dir, WithRecursive(), WithInclude("*.yaml"), WithExclude("*.local.*")).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, d := range ds {
+		names = append(names, d.Name)
+	}
+	want := []string{"10-base.yaml", "20-prod.yaml", "sub/40-sub.yaml", "sub/app.yaml"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("expect %v, got %v", want, names)
+	}
+
+	ds, err = NewSource(dir).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds) != 4 {
+		t.Fatalf("expect only the files in dir, got %d", len(ds))
+	}
+
+	c := config.New(config.WithSource(NewSource(dir, WithRecursive(), WithExclude("*.local.*", "sub/app.yaml"))))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if s, _ := c.Get("level").String(); s != "warn" {
+		t.Fatalf("expect warn, got %s", s)
+	}
+	if s, _ := c.Get("name").String(); s != "sub" {
+		t.Fatalf("expect sub, got %s", s)
+	}
+	if _, err := c.Get("sub").Bool(); err == nil {
+		t.Fatal("expect sub/app.yaml excluded")
+	}
+}
+
+func TestWatchRecursive(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"10-base.yaml": "level: info\n",
+		"20-prod.yaml": "level: warn\n",
+	})
+
+	c := config.New(config.WithSource(NewSource(dir, WithRecursive(), WithDebounce(20*time.Millisecond))))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	changed := make(chan struct{}, 8)
+	if err := c.Watch(func(config.Config) { changed <- struct{}{} }); err != nil {
+		t.Fatal(err)
+	}
+	wait := func() {
+		t.Helper()
+		select {
+		case <-changed:
+		case <-time.After(2 * time.Second):
+			t.Fatal("config is not reloaded")
+		}
+	}
+
+	// a new file sorting first does not override later files
+	writeFiles(t, dir, map[string]string{"05-early.yaml": "level: error\nearly: true\n"})
+	wait()
+	if s, _ := c.Get("level").String(); s != "warn" {
+		t.Fatalf("expect warn, got %s", s)
+	}
+
+	// files in a new subdir are watched
+	writeFiles(t, dir, map[string]string{"sub/30-sub.yaml": "level: debug\n"})
+	wait()
+	if s, _ := c.Get("level").String(); s != "debug" {
+		t.Fatalf("expect debug, got %s", s)
+	}
+	writeFiles(t, dir, map[string]string{"sub/30-sub.yaml": "level: fatal\n"})
+	wait()
+	if s, _ := c.Get("level").String(); s != "fatal" {
+		t.Fatalf("expect fatal, got %s", s)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"config": "level: warn\n", "big.json": `{"data": "0123456789"}`})
+
+	t.Run("format", func(t *testing.T) {
+		if _, err := NewSource(filepath.Join(dir, "config")).Load(); !errors.Is(err, config.ErrUnsupportedFormat) {
+			t.Fatalf("expect ErrUnsupportedFormat, got %v", err)
+		}
+		ds, err := NewSource(filepath.Join(dir, "config"), WithFormat("yaml")).Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ds) != 1 || ds[0].Format != "yaml" || ds[0].Name != "config" {
+			t.Fatalf("unexpected descriptors %+v", ds)
+		}
+		// files of a dir or matched by a glob are loaded by their extension
+		for _, path := range []string{dir, filepath.Join(dir, "*")} {
+			ds, err = NewSource(path, WithFormat("yaml")).Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ds) != 1 || ds[0].Format != "json" || !strings.HasSuffix(ds[0].Name, "big.json") {
+				t.Fatalf("%s: unexpected descriptors %+v", path, ds)
+			}
+		}
+	})
+
+	t.Run("optional", func(t *testing.T) {
+		path := filepath.Join(dir, "missing.yaml")
+		if _, err := NewSource(path).Load(); !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("expect ErrNotExist, got %v", err)
+		}
+		s := NewSource(path, WithOptional(), WithDebounce(0))
+		ds, err := s.Load()
+		if err != nil || len(ds) != 0 {
+			t.Fatalf("expect no config, got %v %v", ds, err)
+		}
+		w, err := s.Watch()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer w.Stop()
+		writeFiles(t, dir, map[string]string{"missing.yaml": "level: info\n"})
+		ds, err = w.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ds) != 1 || ds[0].Name != "missing.yaml" {
+			t.Fatalf("unexpected descriptors %+v", ds)
+		}
+	})
+
+	t.Run("optional missing dir", func(t *testing.T) {
+		root := t.TempDir()
+		c := config.New(config.WithSource(NewSource(filepath.Join(root, "conf.d", "app", "config.yaml"), WithOptional(), WithDebounce(0))))
+		if err := c.Load(); err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		changed := make(chan struct{}, 8)
+		if err := c.Watch(func(config.Config) { changed <- struct{}{} }); err != nil {
+			t.Fatal(err)
+		}
+
+		writeFiles(t, root, map[string]string{"conf.d/app/config.yaml": "level: info\n"})
+		deadline := time.After(2 * time.Second)
+		for {
+			if s, _ := c.Get("level").String(); s == "info" {
+				break
+			}
+			select {
+			case <-changed:
+			case <-deadline:
+				t.Fatal("created file is not loaded")
+			}
+		}
+	})
+
+	t.Run("format reload", func(t *testing.T) {
+		// the watcher reloads the cleaned path
+		c := config.New(config.WithSource(NewSource(dir+"/./config", WithFormat("yaml"), WithDebounce(0))))
+		if err := c.Load(); err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		changed := make(chan struct{}, 8)
+		if err := c.Watch(func(config.Config) { changed <- struct{}{} }); err != nil {
+			t.Fatal(err)
+		}
+
+		writeFiles(t, dir, map[string]string{"config": "level: error\n"})
+		select {
+		case <-changed:
+		case <-time.After(2 * time.Second):
+			t.Fatal("config is not reloaded")
+		}
+		if s, _ := c.Get("level").String(); s != "error" {
+			t.Fatalf("expect error, got %s", s)
+		}
+	})
+
+	t.Run("max size", func(t *testing.T) {
+		path := filepath.Join(dir, "big.json")
+		if _, err := NewSource(path, WithMaxSize(10)).Load(); !errors.Is(err, ErrFileTooLarge) {
+			t.Fatalf("expect ErrFileTooLarge, got %v", err)
+		}
+		if _, err := NewSource(path, WithMaxSize(1<<10)).Load(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("fs", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"etc/app/config":    {Data: []byte("level: debug\n")},
+			"etc/app/app.json":  {Data: []byte(`{"name": "app"}`)},
+			"etc/app/.hidden":   {Data: []byte(`{}`)},
+			"etc/app/notes.txt": {Data: []byte("notes")},
+		}
+		s := NewSource("etc/app", WithFS(fsys))
+		ds, err := s.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ds) != 1 || ds[0].Name != "app.json" {
+			t.Fatalf("unexpected descriptors %+v", ds)
+		}
+		if w, err := s.Watch(); w != nil || err != nil {
+			t.Fatalf("expect no watcher, got %v %v", w, err)
+		}
+		ds, err = NewSource("etc/app/config", WithFS(fsys), WithFormat("yaml")).Load()
+		if err != nil || len(ds) != 1 || string(ds[0].Data) != "level: debug\n" {
+			t.Fatalf("unexpected descriptors %+v %v", ds, err)
+		}
+	})
+}
+
+func TestFSSource(t *testing.T) {
+	defaults := fstest.MapFS{
+		"defaults/10-base.yaml": {Data: []byte("server:\n  addr: :8080\n  timeout: 1s\nlevel: info\n")},
+		"defaults/20-log.json":  {Data: []byte(`{"level": "warn"}`)},
+		"defaults/README.md":    {Data: []byte("# defaults")},
+	}
+
+	s := NewFSSource(defaults, "defaults")
+	if name := s.(fmt.Stringer).String(); name != "fs:defaults" {
+		t.Fatalf("unexpected name %s", name)
+	}
+	ds, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds) != 2 || ds[0].Name != "10-base.yaml" || ds[1].Name != "20-log.json" {
+		t.Fatalf("unexpected descriptors %+v", ds)
+	}
+
+	if _, err := NewFSSource(defaults, "missing").Load(); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expect ErrNotExist, got %v", err)
+	}
+	if ds, err := NewFSSource(defaults, "", WithRecursive(), WithInclude("*.json")).Load(); err != nil || len(ds) != 1 || ds[0].Name != "defaults/20-log.json" {
+		t.Fatalf("unexpected descriptors %+v %v", ds, err)
+	}
+
+	// on-disk overrides layered on the defaults
+	path := filepath.Join(t.TempDir(), "app.yaml")
+	writeFiles(t, filepath.Dir(path), map[string]string{"app.yaml": "server:\n  addr: :9090\n"})
+	c := config.New(config.WithSource(s, NewSource(path)))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var conf struct {
+		Server struct {
+			Addr    string
+			Timeout time.Duration
+		}
+		Level string
+	}
+	if err := c.Scan(&conf); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Server.Addr != ":9090" || conf.Server.Timeout != time.Second || conf.Level != "warn" {
+		t.Fatalf("unexpected config %+v", conf)
+	}
+}
+
+func TestMultiSource(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	etc := filepath.Join(t.TempDir(), "app")
+	local := filepath.Join(t.TempDir(), "app.local.yaml")
+	writeFiles(t, etc, map[string]string{
+		"10-base.yaml": "level: info\nname: base\nport: 80\n",
+		"20-prod.yaml": "level: warn\n",
+		"30-notes.txt": "notes",
+	})
+	writeFiles(t, home, map[string]string{".app.yaml": "name: home\n"})
+	writeFiles(t, filepath.Dir(local), map[string]string{"app.local.yaml": "level: debug\n"})
+
+	s := NewMultiSource([]string{filepath.Join(etc, "*"), "~/.app.yaml", local}, WithDebounce(20*time.Millisecond))
+	ds, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, d := range ds {
+		names = append(names, d.Name)
+	}
+	want := []string{
+		filepath.ToSlash(filepath.Join(etc, "10-base.yaml")),
+		filepath.ToSlash(filepath.Join(etc, "20-prod.yaml")),
+		filepath.ToSlash(filepath.Join(home, ".app.yaml")),
+		filepath.ToSlash(local),
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("expect %v, got %v", want, names)
+	}
+
+	c := config.New(config.WithSource(s))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if s, _ := c.Get("level").String(); s != "debug" {
+		t.Fatalf("expect debug, got %s", s)
+	}
+	if s, _ := c.Get("name").String(); s != "home" {
+		t.Fatalf("expect home, got %s", s)
+	}
+
+	changed := make(chan struct{}, 8)
+	if err := c.Watch(func(config.Config) { changed <- struct{}{} }); err != nil {
+		t.Fatal(err)
+	}
+	wait := func() {
+		t.Helper()
+		select {
+		case <-changed:
+		case <-time.After(2 * time.Second):
+			t.Fatal("config is not reloaded")
+		}
+	}
+
+	// a new match is merged in lexical order of the pattern matches
+	writeFiles(t, etc, map[string]string{"15-new.yaml": "port: 8080\nname: new\n"})
+	wait()
+	if port, _ := c.Get("port").Int(); port != 8080 {
+		t.Fatalf("expect 8080, got %d", port)
+	}
+	if s, _ := c.Get("name").String(); s != "home" {
+		t.Fatalf("expect home, got %s", s)
+	}
+
+	writeFiles(t, etc, map[string]string{"15-new.yaml": "port: 9090\n"})
+	wait()
+	if port, _ := c.Get("port").Int(); port != 9090 {
+		t.Fatalf("expect 9090, got %d", port)
+	}
+
+	writeFiles(t, home, map[string]string{".app.yaml": "name: changed\n"})
+	wait()
+	if s, _ := c.Get("name").String(); s != "changed" {
+		t.Fatalf("expect changed, got %s", s)
+	}
+}
+
+func TestMultiSourceMissingDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"app.yaml": "level: info\n"})
+
+	c := config.New(config.WithSource(NewMultiSource([]string{
+		filepath.Join(dir, "app.yaml"),
+		filepath.Join(dir, "conf.d", "*.yaml"),
+	})))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if s, _ := c.Get("level").String(); s != "info" {
+		t.Fatalf("expect info, got %s", s)
+	}
+}
+
+func TestMultiSourceOrder(t *testing.T) {
+	dir := t.TempDir()
+	override := filepath.Join(t.TempDir(), "b.yaml")
+	writeFiles(t, dir, map[string]string{"a.yaml": "k: dir\n"})
+	writeFiles(t, filepath.Dir(override), map[string]string{"b.yaml": "k: override\n"})
+
+	c := config.New(config.WithSource(NewMultiSource([]string{dir, override}, WithDebounce(20*time.Millisecond))))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	changed := make(chan struct{}, 8)
+	if err := c.Watch(func(config.Config) { changed <- struct{}{} }); err != nil {
+		t.Fatal(err)
+	}
+
+	// a file created in the dir is still merged below the later path
+	writeFiles(t, dir, map[string]string{"c.yaml": "k: dir-new\nnew: true\n"})
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("config is not reloaded")
+	}
+	if v, _ := c.Get("new").Bool(); !v {
+		t.Fatal("new file is not loaded")
+	}
+	if s, _ := c.Get("k").String(); s != "override" {
+		t.Fatalf("expect override, got %s", s)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "a.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, filepath.Dir(override), map[string]string{"b.yaml": "x: 1\n"})
+	deadline := time.After(2 * time.Second)
+	for {
+		if s, _ := c.Get("k").String(); s == "dir-new" {
+			break
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			s, _ := c.Get("k").String()
+			t.Fatalf("expect dir-new, got %s", s)
+		}
+	}
+}
+
+func TestWatchDelete(t *testing.T) {
+	newConfig := func(t *testing.T, s config.Source) (config.Config, func()) {
+		t.Helper()
+		c := config.New(config.WithSource(s))
+		if err := c.Load(); err != nil {
+			t.Fatal(err)
+		}
+		changed := make(chan struct{}, 8)
+		if err := c.Watch(func(config.Config) { changed <- struct{}{} }); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { c.Close() })
+		return c, func() {
+			t.Helper()
+			select {
+			case <-changed:
+			case <-time.After(2 * time.Second):
+				t.Fatal("config is not reloaded")
+			}
+		}
+	}
+	files := map[string]string{
+		"10-base.yaml": "level: info\nname: base\n",
+		"20-prod.yaml": "level: warn\nextra: true\n",
+	}
+
+	t.Run("dir", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, files)
+		c, wait := newConfig(t, NewSource(dir, WithDebounce(20*time.Millisecond)))
+
+		if err := os.Remove(filepath.Join(dir, "20-prod.yaml")); err != nil {
+			t.Fatal(err)
+		}
+		wait()
+		if s, _ := c.Get("level").String(); s != "info" {
+			t.Fatalf("expect info, got %s", s)
+		}
+		if _, err := c.Get("extra").Bool(); !errors.Is(err, config.ErrNotFound) {
+			t.Fatalf("expect extra removed, got %v", err)
+		}
+	})
+
+	t.Run("optional", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, files)
+		c, wait := newConfig(t, NewMultiSource(
+			[]string{filepath.Join(dir, "10-base.yaml"), filepath.Join(dir, "20-prod.yaml")},
+			WithOptional(), WithDebounce(20*time.Millisecond),
+		))
+
+		if err := os.Remove(filepath.Join(dir, "20-prod.yaml")); err != nil {
+			t.Fatal(err)
+		}
+		wait()
+		if s, _ := c.Get("level").String(); s != "info" {
+			t.Fatalf("expect info, got %s", s)
+		}
+		if _, err := c.Get("extra").Bool(); !errors.Is(err, config.ErrNotFound) {
+			t.Fatalf("expect extra removed, got %v", err)
+		}
+	})
+
+	t.Run("glob", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, files)
+		c, wait := newConfig(t, NewSource(filepath.Join(dir, "*.yaml"), WithDebounce(20*time.Millisecond)))
+
+		if err := os.Remove(filepath.Join(dir, "20-prod.yaml")); err != nil {
+			t.Fatal(err)
+		}
+		wait()
+		if s, _ := c.Get("level").String(); s != "info" {
+			t.Fatalf("expect info, got %s", s)
+		}
+		if _, err := c.Get("extra").Bool(); !errors.Is(err, config.ErrNotFound) {
+			t.Fatalf("expect extra removed, got %v", err)
+		}
+	})
+}
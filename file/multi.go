@@ -0,0 +1,480 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sraphs/strslices"
+
+	"github.com/sraphs/config"
+)
+
+var (
+	_ config.SourceContext = (*multi)(nil)
+	_ config.LoggerSetter  = (*multi)(nil)
+)
+
+// multi is a file source of several paths and glob patterns,
+// the matched files are merged in order of the paths, and
+// in lexical order of the files matched by a pattern.
+type multi struct {
+	patterns []string
+	f        *file
+}
+
+// NewMultiSource new a file source of paths, which may start with ~ for
+// the home dir and may be glob patterns. Files matched by a pattern later
+// are loaded once created, descriptors are named by the path of the file.
+func NewMultiSource(paths []string, opts ...Option) config.Source {
+	f := newFile("", opts...)
+	patterns := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if f.fsys == nil {
+			p = expandHome(p)
+		}
+		patterns = append(patterns, p)
+	}
+	if len(patterns) == 1 && !hasMeta(patterns[0]) {
+		f.path = patterns[0]
+		return f
+	}
+	return &multi{patterns: patterns, f: f}
+}
+
+func (m *multi) String() string {
+	return "file:" + strings.Join(m.patterns, ",")
+}
+
+func (m *multi) SetLogger(l config.Logger) {
+	m.f.logger = l
+}
+
+func (m *multi) Load() ([]*config.Descriptor, error) {
+	paths, err := m.expand()
+	if err != nil {
+		return nil, err
+	}
+	return m.load(paths)
+}
+
+func (m *multi) LoadContext(ctx context.Context) ([]*config.Descriptor, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.Load()
+}
+
+func (m *multi) Watch() (config.Watcher, error) {
+	return m.WatchContext(context.Background())
+}
+
+func (m *multi) WatchContext(ctx context.Context) (config.Watcher, error) {
+	if m.f.fsys != nil {
+		// a fs.FS has no change notification
+		return nil, nil
+	}
+	return newMultiWatcher(ctx, m)
+}
+
+// file returns the file source of a matched path, the format
+// option is not applied to files matched by a glob.
+func (m *multi) file(path string) *file {
+	f := *m.f
+	f.path = path
+	if !strslices.Contains(m.patterns, path) {
+		f.format = ""
+	}
+	return &f
+}
+
+// expand returns the paths matched by the patterns, without duplicates.
+func (m *multi) expand() ([]string, error) {
+	var paths []string
+	for _, pattern := range m.patterns {
+		if !hasMeta(pattern) {
+			paths = appendUnique(paths, pattern)
+			continue
+		}
+		matches, err := m.glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			paths = appendUnique(paths, match)
+		}
+	}
+	return paths, nil
+}
+
+// glob returns the config files and dirs matched by pattern in lexical order.
+func (m *multi) glob(pattern string) ([]string, error) {
+	var (
+		matches []string
+		err     error
+	)
+	if m.f.fsys != nil {
+		matches, err = fs.Glob(m.f.fsys, pattern)
+	} else {
+		matches, err = filepath.Glob(pattern)
+	}
+	if err != nil {
+		return nil, err
+	}
+	paths := matches[:0]
+	for _, match := range matches {
+		base := filepath.Base(match)
+		fi, err := m.f.stat(match)
+		if strings.HasPrefix(base, ".") || err == nil && !fi.IsDir() && !isSupported(format(base)) {
+			m.f.logger.Debug("skip config file", "name", match)
+			continue
+		}
+		paths = append(paths, match)
+	}
+	return paths, nil
+}
+
+func (m *multi) load(paths []string) ([]*config.Descriptor, error) {
+	sets, err := m.loadSets(paths)
+	if err != nil {
+		return nil, err
+	}
+	var descs []*config.Descriptor
+	for _, p := range paths {
+		descs = append(descs, sets[p]...)
+	}
+	return descs, nil
+}
+
+// loadSets returns the descriptors of each path.
+func (m *multi) loadSets(paths []string) (map[string][]*config.Descriptor, error) {
+	sets := make(map[string][]*config.Descriptor, len(paths))
+	for _, p := range paths {
+		ds, err := m.file(p).Load()
+		if err != nil {
+			return nil, err
+		}
+		sets[p] = m.rename(p, ds)
+	}
+	return sets, nil
+}
+
+// rename names the descriptors of path by the path of their file,
+// as files of the same name may be matched in different dirs.
+func (m *multi) rename(p string, ds []*config.Descriptor) []*config.Descriptor {
+	fi, err := m.f.stat(p)
+	dir := err == nil && fi.IsDir()
+	for _, d := range ds {
+		if dir {
+			d.Name = path.Join(filepath.ToSlash(p), d.Name)
+		} else {
+			d.Name = filepath.ToSlash(p)
+		}
+	}
+	return ds
+}
+
+// multiWatcher watches the matched paths, and the dirs of the
+// patterns to reload the whole set once the matches change. Any
+// change returns the descriptors of all paths, so that they keep
+// the merge order of Load.
+type multiWatcher struct {
+	m     *multi
+	fw    *fsnotify.Watcher
+	paths []string
+	// sets are the last descriptors of each path
+	sets map[string][]*config.Descriptor
+
+	results chan multiResult
+	gen     int
+	stop    context.CancelFunc
+	wg      sync.WaitGroup
+
+	// names are the descriptors of the matched files
+	names map[string]bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+type multiResult struct {
+	gen  int
+	path string
+	ds   []*config.Descriptor
+	err  error
+}
+
+var _ config.WatcherContext = (*multiWatcher)(nil)
+
+func newMultiWatcher(ctx context.Context, m *multi) (config.Watcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	for _, pattern := range m.patterns {
+		if !hasMeta(pattern) {
+			continue
+		}
+		dir := filepath.Dir(pattern)
+		if hasMeta(dir) {
+			m.f.logger.Debug("new matches of pattern are not watched", "pattern", pattern)
+			continue
+		}
+		if !isDir(dir) {
+			m.f.logger.Warn("dir of pattern is missing, new matches are not watched", "pattern", pattern)
+			continue
+		}
+		if err := fw.Add(dir); err != nil {
+			fw.Close()
+			return nil, err
+		}
+	}
+	w := &multiWatcher{m: m, fw: fw, results: make(chan multiResult)}
+	w.ctx, w.cancel = context.WithCancel(ctx)
+	paths, err := m.expand()
+	if err == nil {
+		w.sets, err = m.loadSets(paths)
+	}
+	if err == nil {
+		err = w.watch(paths)
+	}
+	if err == nil {
+		w.names = w.setNames()
+	}
+	if err != nil {
+		w.Stop()
+		return nil, err
+	}
+	return w, nil
+}
+
+// watch stops the watchers of the previous paths and watches paths.
+func (w *multiWatcher) watch(paths []string) error {
+	if w.stop != nil {
+		w.stop()
+		w.wg.Wait()
+	}
+	ctx, stop := context.WithCancel(w.ctx)
+	w.gen++
+	w.paths, w.stop = paths, stop
+
+	for _, p := range paths {
+		fw, err := newWatcher(ctx, w.m.file(p))
+		if err != nil {
+			return err
+		}
+		w.wg.Add(1)
+		go w.pump(ctx, w.gen, p, fw.(config.WatcherContext))
+	}
+	return nil
+}
+
+func (w *multiWatcher) pump(ctx context.Context, gen int, path string, fw config.WatcherContext) {
+	defer w.wg.Done()
+	defer fw.Stop()
+	for {
+		ds, err := fw.NextContext(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		select {
+		case w.results <- multiResult{gen: gen, path: path, ds: ds, err: err}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (w *multiWatcher) Next() ([]*config.Descriptor, error) {
+	return w.NextContext(context.Background())
+}
+
+func (w *multiWatcher) NextContext(ctx context.Context) ([]*config.Descriptor, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-w.ctx.Done():
+			return nil, w.ctx.Err()
+		case r := <-w.results:
+			if r.gen != w.gen {
+				continue
+			}
+			if errors.Is(r.err, fs.ErrNotExist) {
+				// a matched file may be deleted, which changes the matches
+				if ds, changed, err := w.rematch(); changed || err != nil {
+					return ds, err
+				}
+			}
+			if r.err != nil {
+				return nil, r.err
+			}
+			w.update(r.path, w.m.rename(r.path, r.ds))
+			return w.full(), nil
+		case event := <-w.fw.Events:
+			w.m.f.logger.Debug("config file event", "name", event.Name, "op", event.Op.String())
+			if ds, changed, err := w.rematch(); changed || err != nil {
+				return ds, err
+			}
+		case err := <-w.fw.Errors:
+			return nil, err
+		}
+	}
+}
+
+// rematch watches and loads the full set once the matches change,
+// with tombstones of the files which are no longer loaded.
+func (w *multiWatcher) rematch() ([]*config.Descriptor, bool, error) {
+	w.settle()
+	paths, err := w.m.expand()
+	if err != nil {
+		return nil, false, err
+	}
+	if equalPaths(w.paths, paths) {
+		return nil, false, nil
+	}
+	w.m.f.logger.Debug("config file matches changed", "paths", paths)
+	if err := w.watch(paths); err != nil {
+		return nil, true, err
+	}
+	sets, err := w.m.loadSets(paths)
+	if err != nil {
+		return nil, true, err
+	}
+	w.sets = sets
+	return w.full(), true, nil
+}
+
+// update applies the descriptors of a path to its set, a full set of
+// the path replaces it in its order, others replace or add descriptors.
+func (w *multiWatcher) update(p string, ds []*config.Descriptor) {
+	var (
+		set  = w.sets[p]
+		seen = make(map[string]bool, len(ds))
+	)
+	for _, d := range ds {
+		seen[d.Name] = true
+	}
+	full := true
+	for _, d := range set {
+		full = full && seen[d.Name]
+	}
+	if full {
+		set = nil
+	} else {
+		set = append([]*config.Descriptor(nil), set...)
+	}
+	for _, d := range ds {
+		i := indexDescriptor(set, d.Name)
+		switch {
+		case d.Deleted && i >= 0:
+			set = append(set[:i], set[i+1:]...)
+		case d.Deleted:
+		case i >= 0:
+			set[i] = d
+		default:
+			set = append(set, d)
+		}
+	}
+	w.sets[p] = set
+}
+
+// full returns copies of the descriptors of all paths in order,
+// with tombstones of the files which are no longer loaded.
+func (w *multiWatcher) full() []*config.Descriptor {
+	var ds []*config.Descriptor
+	for _, p := range w.paths {
+		for _, d := range w.sets[p] {
+			c := *d
+			ds = append(ds, &c)
+		}
+	}
+	names := w.setNames()
+	ds = appendTombstones(ds, w.names, names, w.m.f.logger)
+	w.names = names
+	return ds
+}
+
+// setNames returns the names of the descriptors of the watched paths.
+func (w *multiWatcher) setNames() map[string]bool {
+	names := make(map[string]bool)
+	for _, p := range w.paths {
+		for _, d := range w.sets[p] {
+			names[d.Name] = true
+		}
+	}
+	return names
+}
+
+func indexDescriptor(ds []*config.Descriptor, name string) int {
+	for i, d := range ds {
+		if d.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// settle waits until no events of the pattern dirs
+// arrive within the debounce window.
+func (w *multiWatcher) settle() {
+	if w.m.f.debounce <= 0 {
+		return
+	}
+	timer := time.NewTimer(w.m.f.debounce)
+	defer timer.Stop()
+	for {
+		select {
+		case <-w.fw.Events:
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(w.m.f.debounce)
+		case <-timer.C:
+			return
+		case <-w.ctx.Done():
+			return
+		}
+	}
+}
+
+func (w *multiWatcher) Stop() error {
+	w.cancel()
+	w.wg.Wait()
+	return w.fw.Close()
+}
+
+func hasMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// expandHome expands a leading ~ to the home dir.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+func equalPaths(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
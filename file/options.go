@@ -0,0 +1,78 @@
+package file
+
+import (
+	"io/fs"
+	"time"
+)
+
+// defaultDebounce is long enough to coalesce the events of an editor save.
+const defaultDebounce = 100 * time.Millisecond
+
+// Option is file source option.
+type Option func(*file)
+
+// WithDebounce with the window in which the watcher coalesces file events
+// into one change, zero disables it and every event is a change.
+func WithDebounce(d time.Duration) Option {
+	return func(f *file) {
+		f.debounce = d
+	}
+}
+
+// WithRecursive with loading the config files in subdirectories of a dir.
+func WithRecursive() Option {
+	return func(f *file) {
+		f.recursive = true
+	}
+}
+
+// WithInclude with patterns of the config files to load from a dir,
+// matched by path.Match against the base name or the relative path.
+func WithInclude(patterns ...string) Option {
+	return func(f *file) {
+		f.include = append(f.include, patterns...)
+	}
+}
+
+// WithExclude with patterns of the config files to skip in a dir,
+// exclusion takes precedence over inclusion.
+func WithExclude(patterns ...string) Option {
+	return func(f *file) {
+		f.exclude = append(f.exclude, patterns...)
+	}
+}
+
+// WithFormat with the format of a config file, overriding the format
+// by extension, e.g. for an extensionless /etc/myapp/config. It is only
+// applied to a path which is a file, files of a dir or matched by a glob
+// are loaded by their extension.
+func WithFormat(format string) Option {
+	return func(f *file) {
+		f.format = format
+	}
+}
+
+// WithOptional with a missing path loading no config instead of an error,
+// the watcher loads the file once created.
+func WithOptional() Option {
+	return func(f *file) {
+		f.optional = true
+	}
+}
+
+// WithFS with the file system the path is resolved in, the path is then a
+// slash separated fs.FS path. A fs.FS has no change notification, so the
+// source is not watched.
+func WithFS(fsys fs.FS) Option {
+	return func(f *file) {
+		f.fsys = fsys
+	}
+}
+
+// WithMaxSize with the maximum size in bytes of a config file,
+// larger files fail to load with ErrFileTooLarge.
+func WithMaxSize(n int64) Option {
+	return func(f *file) {
+		f.maxSize = n
+	}
+}
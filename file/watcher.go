@@ -4,65 +4,323 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 
 	"github.com/sraphs/config"
 )
 
+// watcher watches the directory of a file instead of the file itself,
+// so that atomic saves and symlink swaps, such as the ..data swap of
+// a Kubernetes ConfigMap volume, are not missed.
 type watcher struct {
-	f  *file
-	fw *fsnotify.Watcher
+	f    *file
+	fw   *fsnotify.Watcher
+	path string
+
+	// targets maps the watched files to the files they resolve to,
+	// a change of targets is a swap and reloads the whole file set.
+	targets map[string]string
+	watched map[string]bool
+	// names are the descriptors of the last full set
+	names map[string]bool
 
 	ctx    context.Context
 	cancel context.CancelFunc
 }
 
-var _ config.Watcher = (*watcher)(nil)
+var _ config.WatcherContext = (*watcher)(nil)
 
-func newWatcher(f *file) (config.Watcher, error) {
+func newWatcher(ctx context.Context, f *file) (config.Watcher, error) {
 	fw, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, err
 	}
-	if err := fw.Add(f.path); err != nil {
+	w := &watcher{
+		f:       f,
+		fw:      fw,
+		path:    filepath.Clean(f.path),
+		watched: make(map[string]bool),
+	}
+	w.targets = w.resolve()
+	if w.targets[w.path] == "" && !f.optional {
+		fw.Close()
+		_, err := os.Stat(f.path)
+		return nil, err
+	}
+	if err := w.sync(); err != nil {
+		fw.Close()
 		return nil, err
 	}
-	ctx, cancel := context.WithCancel(context.Background())
-	return &watcher{f: f, fw: fw, ctx: ctx, cancel: cancel}, nil
+	w.names = f.names()
+	w.ctx, w.cancel = context.WithCancel(ctx)
+	return w, nil
 }
 
 func (w *watcher) Next() ([]*config.Descriptor, error) {
-	select {
-	case <-w.ctx.Done():
-		return nil, w.ctx.Err()
-	case event := <-w.fw.Events:
-		if event.Op == fsnotify.Rename {
-			if _, err := os.Stat(event.Name); err == nil || os.IsExist(err) {
-				if err := w.fw.Add(event.Name); err != nil {
-					return nil, err
+	return w.NextContext(context.Background())
+}
+
+// NextContext waits for file events, the events arriving within
+// the debounce window of each other are coalesced into one change.
+func (w *watcher) NextContext(ctx context.Context) ([]*config.Descriptor, error) {
+	var (
+		paths []string
+		timer *time.Timer
+		fire  <-chan time.Time
+	)
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-w.ctx.Done():
+			return nil, w.ctx.Err()
+		case event := <-w.fw.Events:
+			w.f.logger.Debug("config file event", "name", event.Name, "op", event.Op.String())
+			if path := w.lookup(event.Name); path != "" {
+				paths = appendUnique(paths, path)
+			}
+			if w.f.debounce <= 0 {
+				break
+			}
+			if timer == nil {
+				timer = time.NewTimer(w.f.debounce)
+				fire = timer.C
+			} else {
+				if !timer.Stop() {
+					<-timer.C
 				}
+				timer.Reset(w.f.debounce)
 			}
+			continue
+		case <-fire:
+			timer = nil
+		case err := <-w.fw.Errors:
+			return nil, err
 		}
-		fi, err := os.Stat(w.f.path)
-		if err != nil {
+
+		ds, err := w.load(paths)
+		if err != nil || len(ds) > 0 {
+			return ds, err
+		}
+		paths, fire = nil, nil
+	}
+}
+
+// load loads the changed files, or the whole file set after a swap.
+func (w *watcher) load(paths []string) ([]*config.Descriptor, error) {
+	if w.f.optional {
+		// watch the created dirs of the path before resolving it
+		if err := w.sync(); err != nil {
 			return nil, err
 		}
-		path := w.f.path
-		if fi.IsDir() {
-			path = filepath.Join(w.f.path, filepath.Base(event.Name))
+	}
+	targets := w.resolve()
+	if !equalTargets(w.targets, targets) {
+		w.f.logger.Debug("config file targets changed", "path", w.f.path)
+		w.targets = targets
+		if err := w.sync(); err != nil {
+			return nil, err
+		}
+		ds, err := w.f.Load()
+		if err != nil {
+			return nil, err
 		}
+		return w.tombstone(ds), nil
+	}
+
+	ds := make([]*config.Descriptor, 0, len(paths))
+	for _, path := range paths {
 		d, err := w.f.loadFile(path)
 		if err != nil {
 			return nil, err
 		}
-		return []*config.Descriptor{d}, nil
-	case err := <-w.fw.Errors:
-		return nil, err
+		ds = append(ds, d)
 	}
+	return ds, nil
+}
+
+// lookup returns the watched file changed by an event of name,
+// or empty if the event is not about a watched file.
+func (w *watcher) lookup(name string) string {
+	name = filepath.Clean(name)
+	if name != w.path && isDir(name) {
+		return ""
+	}
+	if _, ok := w.targets[name]; ok && name != w.path {
+		return name
+	}
+	for path, target := range w.targets {
+		if target == name && path != w.path {
+			return path
+		}
+	}
+	if !w.isDir() {
+		if name == w.path || name == w.targets[w.path] {
+			return w.path
+		}
+		return ""
+	}
+	for _, root := range []string{w.path, w.targets[w.path]} {
+		rel, err := filepath.Rel(root, name)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		// only files in walked dirs, a new dir changes the targets
+		if dir := filepath.Join(w.path, filepath.Dir(rel)); dir != w.path && w.targets[dir] == "" {
+			return ""
+		}
+		if !w.f.match(rel) {
+			w.f.logger.Debug("skip config file", "name", name)
+			return ""
+		}
+		return filepath.Join(w.path, rel)
+	}
+	return ""
+}
+
+func (w *watcher) isDir() bool {
+	return isDir(w.targets[w.path])
+}
+
+func isDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
+
+// tombstone appends tombstones of the files loaded before but not in the
+// full set ds, so that the config removes the values of deleted files.
+func (w *watcher) tombstone(ds []*config.Descriptor) []*config.Descriptor {
+	names := make(map[string]bool, len(ds))
+	for _, d := range ds {
+		names[d.Name] = true
+	}
+	ds = appendTombstones(ds, w.names, names, w.f.logger)
+	w.names = names
+	return ds
+}
+
+// appendTombstones appends to ds tombstones of the names in prev
+// but not in next, in lexical order.
+func appendTombstones(ds []*config.Descriptor, prev, next map[string]bool, logger config.Logger) []*config.Descriptor {
+	var deleted []string
+	for name := range prev {
+		if !next[name] {
+			deleted = append(deleted, name)
+		}
+	}
+	sort.Strings(deleted)
+	for _, name := range deleted {
+		logger.Debug("config file deleted", "name", name)
+		ds = append(ds, &config.Descriptor{Name: name, Deleted: true})
+	}
+	return ds
+}
+
+// resolve resolves the symlinks of the watched path and,
+// if it is a directory, of the config files and subdirectories in it.
+func (w *watcher) resolve() map[string]string {
+	targets := map[string]string{w.path: evalSymlinks(w.path)}
+	files, dirs, err := w.f.walk(w.path)
+	if err != nil {
+		return targets
+	}
+	for _, path := range append(files, dirs...) {
+		targets[path] = evalSymlinks(path)
+	}
+	return targets
+}
+
+// sync watches the directories of the watched path
+// and the targets of symlinks, and unwatches the rest.
+func (w *watcher) sync() error {
+	dir := w.isDir()
+	want := map[string]bool{}
+	if !dir || w.targets[w.path] != w.path {
+		want[w.parent()] = true
+	}
+	for path, target := range w.targets {
+		if target == "" {
+			continue
+		}
+		if target != path || dir && isDir(target) {
+			want[target] = true
+		}
+	}
+
+	for path := range w.watched {
+		if !want[path] {
+			// the path may be gone with its watch already
+			_ = w.fw.Remove(path)
+			delete(w.watched, path)
+		}
+	}
+	for path := range want {
+		if w.watched[path] {
+			continue
+		}
+		if err := w.fw.Add(path); err != nil {
+			return err
+		}
+		w.watched[path] = true
+	}
+	return nil
+}
+
+// parent returns the dir of the watched path, or its nearest existing
+// ancestor if the dir is missing and the path optional, so that the
+// creation of the dir is seen.
+func (w *watcher) parent() string {
+	dir := filepath.Dir(w.path)
+	for w.f.optional && !isDir(dir) {
+		up := filepath.Dir(dir)
+		if up == dir {
+			break
+		}
+		dir = up
+	}
+	return dir
 }
 
 func (w *watcher) Stop() error {
 	w.cancel()
 	return w.fw.Close()
 }
+
+func evalSymlinks(path string) string {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return ""
+	}
+	return target
+}
+
+func equalTargets(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if t, ok := b[k]; !ok || t != v {
+			return false
+		}
+	}
+	return true
+}
+
+func appendUnique(s []string, v string) []string {
+	for _, e := range s {
+		if e == v {
+			return s
+		}
+	}
+	return append(s, v)
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sraphs/config/internal/secret"
+)
+
+// filesSource is the name the reloads of referenced files are reported by.
+const filesSource = "placeholder:file"
+
+var placeholderRegexp = regexp.MustCompile(`\${([^}]*)}`)
+
+// filePlaceholder returns the path of a ${file:/path} placeholder name.
+func filePlaceholder(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	if !strings.HasPrefix(name, "file:") || name == "file:" {
+		return "", false
+	}
+	return name[len("file:"):], true
+}
+
+// filePlaceholders returns the sorted paths referenced by ${file:/path} placeholders in m.
+func filePlaceholders(m map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch vt := v.(type) {
+		case string:
+			for _, match := range placeholderRegexp.FindAllStringSubmatch(vt, -1) {
+				if path, ok := filePlaceholder(match[1]); ok {
+					seen[path] = true
+				}
+			}
+		case map[string]interface{}:
+			for _, sub := range vt {
+				walk(sub)
+			}
+		case []interface{}:
+			for _, sub := range vt {
+				walk(sub)
+			}
+		}
+	}
+	walk(m)
+
+	paths := make([]string, 0, len(seen))
+	for path := range seen {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// watchFiles starts watching the files referenced by placeholders
+// once there are any, so that rotated secrets are re-read.
+func (c *config) watchFiles(ctx context.Context) error {
+	if len(c.current().referencedFiles()) == 0 {
+		return nil
+	}
+	c.mu.Lock()
+	w := c.files
+	c.mu.Unlock()
+	if w != nil {
+		w.refresh()
+		return nil
+	}
+
+	sw, err := secret.NewWatcher()
+	if err != nil {
+		return err
+	}
+	w = &filesWatcher{c: c, sw: sw, changed: make(chan struct{}, 1)}
+	if err := sw.Watch(c.current().referencedFiles()); err != nil {
+		sw.Close()
+		return err
+	}
+	c.mu.Lock()
+	if c.files != nil {
+		c.mu.Unlock()
+		return sw.Close()
+	}
+	c.files = w
+	c.mu.Unlock()
+	c.startWatch(ctx, w, filesSource, -1, 0)
+	return nil
+}
+
+func (r *reader) referencedFiles() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.files
+}
+
+// filesWatcher watches the dirs of the files referenced by placeholders,
+// a change is an empty set of descriptors which re-resolves the config.
+type filesWatcher struct {
+	c       *config
+	sw      *secret.Watcher
+	changed chan struct{}
+}
+
+var _ WatcherContext = (*filesWatcher)(nil)
+
+// refresh makes the watcher watch the files referenced after a reload.
+func (w *filesWatcher) refresh() {
+	select {
+	case w.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (w *filesWatcher) Next() ([]*Descriptor, error) {
+	return w.NextContext(context.Background())
+}
+
+func (w *filesWatcher) NextContext(ctx context.Context) ([]*Descriptor, error) {
+	for {
+		if err := w.sw.Watch(w.c.current().referencedFiles()); err != nil {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-w.changed:
+			continue
+		case err, ok := <-w.sw.Errors():
+			if !ok {
+				return nil, context.Canceled
+			}
+			return nil, err
+		case event, ok := <-w.sw.Events():
+			if !ok {
+				return nil, context.Canceled
+			}
+			w.c.logger().Debug("referenced file event", "name", event.Name, "op", event.Op.String())
+		}
+
+		// wait until the rotation settles
+		if err := w.sw.Settle(ctx); err != nil {
+			return nil, err
+		}
+		return []*Descriptor{}, nil
+	}
+}
+
+func (w *filesWatcher) Stop() error {
+	return w.sw.Close()
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFilePlaceholders(t *testing.T) {
+	m := map[string]interface{}{
+		"a": "${file:/run/secrets/a}",
+		"b": map[string]interface{}{"c": "x ${ file:b.txt} ${file:/run/secrets/a}"},
+		"d": []interface{}{"${key:default}", "${file:}"},
+	}
+	if got, want := filePlaceholders(m), []string{"/run/secrets/a", "b.txt"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expect %v, got %v", want, got)
+	}
+}
+
+func TestConfig_FilePlaceholder(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "db_password")
+	if err := os.WriteFile(secret, []byte("s3cret\n\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(WithSource(newTestJSONSource(`{"db": {"user": "app", "password": "${file:` + secret + `}", "dsn": "${db.user}:${file:` + secret + `}@db"}}`)))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if s, _ := c.Get("db.password").String(); s != "s3cret" {
+		t.Fatalf("expect s3cret, got %q", s)
+	}
+	if s, _ := c.Get("db.dsn").String(); s != "app:s3cret@db" {
+		t.Fatalf("expect app:s3cret@db, got %q", s)
+	}
+
+	changed := make(chan struct{}, 4)
+	if err := c.WatchKey("db.password", func(_, _ Value) { changed <- struct{}{} }); err != nil {
+		t.Fatal(err)
+	}
+	// rotate the secret atomically
+	tmp := filepath.Join(dir, "db_password.tmp")
+	if err := os.WriteFile(tmp, []byte("rotated\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, secret); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("secret is not re-read")
+	}
+	if s, _ := c.Get("db.password").String(); s != "rotated" {
+		t.Fatalf("expect rotated, got %q", s)
+	}
+
+	missing := New(WithSource(newTestJSONSource(`{"password": "${file:` + filepath.Join(dir, "missing") + `}"}`)))
+	if err := missing.Load(); err == nil {
+		t.Fatal("expect error for a missing file")
+	}
+	missing.Close()
+}
@@ -3,28 +3,43 @@ package flag
 import (
 	"bytes"
 	"os"
+	"strings"
 
 	"github.com/sraphs/config"
 )
 
-var _ config.Source = (*flag)(nil)
+var (
+	_ config.Source       = (*flag)(nil)
+	_ config.LoggerSetter = (*flag)(nil)
+)
 
 type flag struct {
-	args []string
+	args   []string
+	logger config.Logger
 }
 
 func NewSource() config.Source {
-	return &flag{args: os.Args[1:]}
+	return &flag{args: os.Args[1:], logger: config.NopLogger}
+}
+
+func (f *flag) String() string {
+	return "flag"
+}
+
+func (f *flag) SetLogger(l config.Logger) {
+	f.logger = l
 }
 
 func (f *flag) Load() ([]*config.Descriptor, error) {
 	var buf bytes.Buffer
 
 	for _, arg := range f.args {
-		buf.WriteString(arg)
+		buf.WriteString(normalizeArg(arg))
 		buf.WriteByte(' ')
 	}
 
+	f.logger.Debug("load flags", "args", len(f.args))
+
 	d := &config.Descriptor{
 		Name:   "flag",
 		Data:   buf.Bytes(),
@@ -37,3 +52,19 @@ func (f *flag) Load() ([]*config.Descriptor, error) {
 func (f *flag) Watch() (config.Watcher, error) {
 	return nil, nil
 }
+
+// normalizeArg normalizes the flag name of arg, e.g. "--Log.Level=warn"
+// becomes "--log.level=warn", other arguments are returned as is.
+func normalizeArg(arg string) string {
+	name := strings.TrimLeft(arg, "-")
+	if name == arg || name == "" {
+		return arg
+	}
+	dashes := arg[:len(arg)-len(name)]
+	name, value, hasValue := strings.Cut(name, "=")
+	name = dashes + config.NormalizeKey(name, ".")
+	if hasValue {
+		return name + "=" + value
+	}
+	return name
+}
@@ -4,7 +4,6 @@ go 1.18
 
 require (
 	github.com/fsnotify/fsnotify v1.5.4
-	github.com/imdario/mergo v0.3.12
 	github.com/sraphs/encoding v1.0.5
 	github.com/sraphs/maps v1.0.0
 	github.com/sraphs/strslices v1.0.0
@@ -25,6 +24,5 @@ require (
 	github.com/tidwall/pretty v1.2.0 // indirect
 	golang.org/x/sys v0.0.0-20220412211240-33da011f77ad // indirect
 	google.golang.org/genproto v0.0.0-20220519153652-3a47de7e79bd // indirect
-	gopkg.in/yaml.v2 v2.4.0 // indirect
 	gopkg.in/yaml.v3 v3.0.0 // indirect
 )
@@ -0,0 +1,101 @@
+// Package secret reads secret files, such as mounted Kubernetes
+// secrets, and watches them for rotation.
+package secret
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Debounce coalesces the events of a rotated file.
+const Debounce = 100 * time.Millisecond
+
+// ReadFile returns the contents of a file without trailing newlines.
+func ReadFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// Watcher watches the dirs of files, rather than the files,
+// so that rotation by rename or symlink swap is not missed.
+type Watcher struct {
+	fw   *fsnotify.Watcher
+	dirs map[string]bool
+}
+
+func NewWatcher() (*Watcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	return &Watcher{fw: fw, dirs: make(map[string]bool)}, nil
+}
+
+// Watch watches the dirs of files, and stops watching other dirs.
+func (w *Watcher) Watch(files []string) error {
+	want := make(map[string]bool)
+	for _, path := range files {
+		want[filepath.Dir(path)] = true
+	}
+	for dir := range w.dirs {
+		if !want[dir] {
+			_ = w.fw.Remove(dir)
+			delete(w.dirs, dir)
+		}
+	}
+	for dir := range want {
+		if w.dirs[dir] {
+			continue
+		}
+		if err := w.fw.Add(dir); err != nil {
+			return err
+		}
+		w.dirs[dir] = true
+	}
+	return nil
+}
+
+// Events returns the events of the watched dirs,
+// it is closed once the watcher is closed.
+func (w *Watcher) Events() <-chan fsnotify.Event {
+	return w.fw.Events
+}
+
+// Errors returns the errors of the watched dirs.
+func (w *Watcher) Errors() <-chan error {
+	return w.fw.Errors
+}
+
+// Settle waits until no events arrive within Debounce.
+func (w *Watcher) Settle(ctx context.Context) error {
+	timer := time.NewTimer(Debounce)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case _, ok := <-w.fw.Events:
+			if !ok {
+				return context.Canceled
+			}
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(Debounce)
+		case <-timer.C:
+			return nil
+		}
+	}
+}
+
+func (w *Watcher) Close() error {
+	return w.fw.Close()
+}
@@ -0,0 +1,56 @@
+package secret
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(path, []byte("s3cret\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := ReadFile(path); err != nil || s != "s3cret" {
+		t.Fatalf("expect s3cret, got %q %v", s, err)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "password")
+	w, err := NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := w.Watch([]string{path}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a rotation by rename is seen in the watched dir
+	tmp := filepath.Join(dir, "password.tmp")
+	if err := os.WriteFile(tmp, []byte("s3cret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-w.Events():
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event")
+	}
+	if err := w.Settle(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Watch(nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.dirs) != 0 {
+		t.Fatalf("expect no watched dirs, got %v", w.dirs)
+	}
+}
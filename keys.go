@@ -0,0 +1,101 @@
+package config
+
+import (
+	"sort"
+	"strings"
+)
+
+// NormalizeKey returns the canonical path of a raw source key, like
+// "APP_LOG_LEVEL" from env or "Log.Level" from flags: the segments
+// split by sep are lower cased and joined by ".".
+func NormalizeKey(key, sep string) string {
+	parts := strings.Split(strings.TrimSpace(key), sep)
+	segments := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			segments = append(segments, strings.ToLower(p))
+		}
+	}
+	return strings.Join(segments, ".")
+}
+
+// foldKey returns the form of a key segment used to match keys loosely,
+// "readTimeout", "read_timeout" and "READ-TIMEOUT" are all equal.
+func foldKey(key string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(key))
+}
+
+// lookupKey returns the key of m matching key, exactly or after folding.
+func lookupKey(m map[string]interface{}, key string) (string, bool) {
+	if _, ok := m[key]; ok {
+		return key, true
+	}
+	folded := foldKey(key)
+	for _, k := range sortedKeys(m) {
+		if foldKey(k) == folded {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// looseKeys reports whether the keys of d are derived from names, like
+// those of env variables or flags, rather than keys of structured data.
+func looseKeys(d *Descriptor) bool {
+	switch d.Format {
+	case "", "env", "flag":
+		return true
+	}
+	return d.Normalized
+}
+
+// expandKeys returns a copy of m where dotted keys like "a.b" are
+// expanded into nested maps, and keys equal after folding are merged.
+// Only the keys of m are expanded, nested maps are values kept as is.
+func expandKeys(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for _, k := range sortedKeys(m) {
+		v := m[k]
+		keys := strings.Split(k, ".")
+		for i := len(keys) - 1; i > 0; i-- {
+			v = map[string]interface{}{keys[i]: v}
+		}
+		mergeValues(out, map[string]interface{}{keys[0]: v}, true)
+	}
+	return out
+}
+
+// mergeValues merges src into dst, nested maps are merged recursively
+// and other values of src override dst. Keys are matched exactly, or
+// after folding if loose, the existing key of dst is kept.
+func mergeValues(dst, src map[string]interface{}, loose bool) {
+	for _, k := range sortedKeys(src) {
+		v := src[k]
+		key, ok := k, false
+		if loose {
+			key, ok = lookupKey(dst, k)
+		} else {
+			_, ok = dst[k]
+		}
+		if !ok {
+			dst[k] = v
+			continue
+		}
+		dm, dok := dst[key].(map[string]interface{})
+		sm, sok := v.(map[string]interface{})
+		if dok && sok {
+			mergeValues(dm, sm, loose)
+			continue
+		}
+		dst[key] = v
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNormalizeKey(t *testing.T) {
+	tests := []struct {
+		key    string
+		sep    string
+		expect string
+	}{
+		{"LOG_LEVEL", "_", "log.level"},
+		{"Server__HTTP_Addr", "_", "server.http.addr"},
+		{"Log.Level", ".", "log.level"},
+		{"read-timeout", ".", "read-timeout"},
+		{" a..b ", ".", "a.b"},
+	}
+	for _, test := range tests {
+		if actual := NormalizeKey(test.key, test.sep); actual != test.expect {
+			t.Errorf("NormalizeKey(%q, %q): expect %q, actual %q", test.key, test.sep, test.expect, actual)
+		}
+	}
+}
+
+func TestExpandKeys(t *testing.T) {
+	actual := expandKeys(map[string]interface{}{
+		"a.b":   1,
+		"a":     map[string]interface{}{"c": 2},
+		"x.y_z": 3,
+		"x":     map[string]interface{}{"yZ": 4},
+	})
+	expect := map[string]interface{}{
+		"a": map[string]interface{}{"b": 1, "c": 2},
+		"x": map[string]interface{}{"yZ": 3},
+	}
+	if !reflect.DeepEqual(expect, actual) {
+		t.Fatalf("expect %v, actual %v", expect, actual)
+	}
+}
+
+func TestConfig_GetScanAgree(t *testing.T) {
+	c := New(WithSource(
+		&testNamedSource{name: "file", data: `{"data": {"redis": {"read_timeout": "1s", "addr": ":6379"}}}`},
+		&testNamedSource{name: "environ", data: "DATA_REDIS_READTIMEOUT=2s\nlog.level=warn", format: "env"},
+		&testNamedSource{name: "flag", data: "--Data.Redis.Addr=:6380", format: "flag"},
+	))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	var conf struct {
+		Log struct {
+			Level string `json:"level"`
+		} `json:"log"`
+		Data struct {
+			Redis struct {
+				Addr        string        `json:"addr"`
+				ReadTimeout time.Duration `json:"read_timeout"`
+			} `json:"redis"`
+		} `json:"data"`
+	}
+	if err := c.Scan(&conf); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"data.redis.read_timeout", "Data.Redis.ReadTimeout", "data.redis.read-timeout"} {
+		if d, err := c.Get(key).Duration(); err != nil || d != conf.Data.Redis.ReadTimeout || d != 2*time.Second {
+			t.Errorf("%s: expect %v, actual %v %v", key, conf.Data.Redis.ReadTimeout, d, err)
+		}
+	}
+	if addr, _ := c.Get("data.redis.addr").String(); addr != conf.Data.Redis.Addr || addr != ":6380" {
+		t.Errorf("expect %s, actual %s", conf.Data.Redis.Addr, addr)
+	}
+	if level, _ := c.Get("log.level").String(); level != conf.Log.Level || level != "warn" {
+		t.Errorf("expect %s, actual %s", conf.Log.Level, level)
+	}
+}
+
+func TestConfig_StructuredKeys(t *testing.T) {
+	c := New(WithSource(
+		&testNamedSource{name: "file", data: `{"hosts": {"example.com": "a", "10.0.0.1": "b"}, "labels": {"App": "x", "app": "y"}, "read_timeout": "1s", "readtimeout": "2s"}`},
+	))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	var conf struct {
+		Hosts  map[string]string `json:"hosts"`
+		Labels map[string]string `json:"labels"`
+	}
+	if err := c.Scan(&conf); err != nil {
+		t.Fatal(err)
+	}
+	if expect := map[string]string{"example.com": "a", "10.0.0.1": "b"}; !reflect.DeepEqual(expect, conf.Hosts) {
+		t.Errorf("expect %v, actual %v", expect, conf.Hosts)
+	}
+	if expect := map[string]string{"App": "x", "app": "y"}; !reflect.DeepEqual(expect, conf.Labels) {
+		t.Errorf("expect %v, actual %v", expect, conf.Labels)
+	}
+
+	for key, expect := range map[string]string{"labels.App": "x", "labels.app": "y", "read_timeout": "1s", "readtimeout": "2s"} {
+		if actual, _ := c.Get(key).String(); actual != expect {
+			t.Errorf("%s: expect %s, actual %s", key, expect, actual)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+)
+
+// Logger is a leveled structured logger, keyvals are alternating
+// keys and values. It is satisfied by *slog.Logger.
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+}
+
+// LoggerSetter is implemented by sources which log,
+// Config passes them its logger before loading.
+type LoggerSetter interface {
+	SetLogger(Logger)
+}
+
+// NopLogger is a logger which discards everything, it is the default logger.
+var NopLogger Logger = nopLogger{}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
+
+type stdLogger struct {
+	log *log.Logger
+}
+
+// NewStdLogger returns a logger writing "LEVEL msg key=value" lines to w.
+func NewStdLogger(w io.Writer) Logger {
+	return &stdLogger{log: log.New(w, "", log.LstdFlags)}
+}
+
+func (l *stdLogger) Debug(msg string, keyvals ...interface{}) { l.output("DEBUG", msg, keyvals) }
+func (l *stdLogger) Info(msg string, keyvals ...interface{})  { l.output("INFO", msg, keyvals) }
+func (l *stdLogger) Warn(msg string, keyvals ...interface{})  { l.output("WARN", msg, keyvals) }
+func (l *stdLogger) Error(msg string, keyvals ...interface{}) { l.output("ERROR", msg, keyvals) }
+
+func (l *stdLogger) output(level, msg string, keyvals []interface{}) {
+	var b strings.Builder
+	b.WriteString(level)
+	b.WriteByte(' ')
+	b.WriteString(msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		var v interface{} = "MISSING"
+		if i+1 < len(keyvals) {
+			v = keyvals[i+1]
+		}
+		fmt.Fprintf(&b, " %v=%v", keyvals[i], v)
+	}
+	l.log.Print(b.String())
+}
+
+// fieldsLogger adds fields to every entry of a logger.
+type fieldsLogger struct {
+	logger Logger
+	fields []interface{}
+}
+
+// WithFields returns a logger which adds keyvals to every entry of l.
+func WithFields(l Logger, keyvals ...interface{}) Logger {
+	if _, ok := l.(nopLogger); ok {
+		return l
+	}
+	return &fieldsLogger{logger: l, fields: keyvals}
+}
+
+func (l *fieldsLogger) Debug(msg string, keyvals ...interface{}) {
+	l.logger.Debug(msg, l.with(keyvals)...)
+}
+
+func (l *fieldsLogger) Info(msg string, keyvals ...interface{}) {
+	l.logger.Info(msg, l.with(keyvals)...)
+}
+
+func (l *fieldsLogger) Warn(msg string, keyvals ...interface{}) {
+	l.logger.Warn(msg, l.with(keyvals)...)
+}
+
+func (l *fieldsLogger) Error(msg string, keyvals ...interface{}) {
+	l.logger.Error(msg, l.with(keyvals)...)
+}
+
+func (l *fieldsLogger) with(keyvals []interface{}) []interface{} {
+	return append(append(make([]interface{}, 0, len(l.fields)+len(keyvals)), l.fields...), keyvals...)
+}
+
+// sourceName returns the name of src used in logs and errors.
+func sourceName(src Source) string {
+	if s, ok := src.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", src)
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type testLogger struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (l *testLogger) log(level, msg string, keyvals []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, fmt.Sprint(level, " ", msg, " ", keyvals))
+}
+
+func (l *testLogger) Debug(msg string, keyvals ...interface{}) { l.log("DEBUG", msg, keyvals) }
+func (l *testLogger) Info(msg string, keyvals ...interface{})  { l.log("INFO", msg, keyvals) }
+func (l *testLogger) Warn(msg string, keyvals ...interface{})  { l.log("WARN", msg, keyvals) }
+func (l *testLogger) Error(msg string, keyvals ...interface{}) { l.log("ERROR", msg, keyvals) }
+
+func TestWithLogger(t *testing.T) {
+	logger := &testLogger{}
+	c := New(
+		WithSource(&testNamedSource{name: "base", data: `{"a": 1}`}),
+		WithLogger(logger),
+	)
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := "INFO load config [source *config.testNamedSource name base format json priority 0]"
+	found := false
+	for _, e := range logger.entries {
+		found = found || e == expect
+	}
+	if !found {
+		t.Fatalf("expect entry %q, got %q", expect, logger.entries)
+	}
+}
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := WithFields(NewStdLogger(&buf), "source", "file:conf.yaml")
+	logger.Warn("rejected config reload", "revision", 2, "error")
+
+	expect := "WARN rejected config reload source=file:conf.yaml revision=2 error=MISSING\n"
+	if !strings.HasSuffix(buf.String(), expect) {
+		t.Fatalf("expect suffix %q, got %q", expect, buf.String())
+	}
+	if WithFields(NopLogger, "a", 1) != NopLogger {
+		t.Fatal("expect NopLogger stays silent")
+	}
+}
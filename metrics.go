@@ -0,0 +1,33 @@
+package config
+
+import "time"
+
+// Metrics receives the instrumentation of config activity,
+// see the metrics package for a Prometheus compatible implementation.
+type Metrics interface {
+	// LoadDuration is called after a source is loaded.
+	LoadDuration(source string, d time.Duration, err error)
+	// Reload is called after a reload of a source is applied or rejected.
+	Reload(source string, err error)
+	// MergeFailure is called when a config of a source fails to merge.
+	MergeFailure(source string)
+	// ResolveFailure is called when a config of a source fails to resolve,
+	// source is empty when the whole config is resolved on load.
+	ResolveFailure(source string)
+	// ObserverPanic is called when an observer panics.
+	ObserverPanic()
+	// Revision is called when the revision of the config changes.
+	Revision(revision uint64)
+}
+
+// NopMetrics is a metrics which discards everything, it is the default metrics.
+var NopMetrics Metrics = nopMetrics{}
+
+type nopMetrics struct{}
+
+func (nopMetrics) LoadDuration(string, time.Duration, error) {}
+func (nopMetrics) Reload(string, error)                      {}
+func (nopMetrics) MergeFailure(string)                       {}
+func (nopMetrics) ResolveFailure(string)                     {}
+func (nopMetrics) ObserverPanic()                            {}
+func (nopMetrics) Revision(uint64)                           {}
@@ -0,0 +1,155 @@
+// Package metrics implements config.Metrics and exposes
+// the collected values in the Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sraphs/config"
+)
+
+var _ config.Metrics = (*Metrics)(nil)
+var _ http.Handler = (*Metrics)(nil)
+
+// Metrics collects config metrics, it is safe for concurrent use.
+type Metrics struct {
+	mu             sync.Mutex
+	loadDurations  map[string]float64
+	loadFailures   map[string]uint64
+	reloads        map[[2]string]uint64
+	mergeFailures  map[string]uint64
+	resolveFailure map[string]uint64
+	observerPanics uint64
+	revision       uint64
+}
+
+// New new a Metrics.
+func New() *Metrics {
+	return &Metrics{
+		loadDurations:  make(map[string]float64),
+		loadFailures:   make(map[string]uint64),
+		reloads:        make(map[[2]string]uint64),
+		mergeFailures:  make(map[string]uint64),
+		resolveFailure: make(map[string]uint64),
+	}
+}
+
+func (m *Metrics) LoadDuration(source string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.loadDurations[source] = d.Seconds()
+	if err != nil {
+		m.loadFailures[source]++
+	}
+}
+
+func (m *Metrics) Reload(source string, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reloads[[2]string{source, result}]++
+}
+
+func (m *Metrics) MergeFailure(source string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mergeFailures[source]++
+}
+
+func (m *Metrics) ResolveFailure(source string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.resolveFailure[source]++
+}
+
+func (m *Metrics) ObserverPanic() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observerPanics++
+}
+
+func (m *Metrics) Revision(revision uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.revision = revision
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text format to w.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+	header(&b, "config_load_duration_seconds", "gauge", "Duration of the last load of a config source.")
+	for _, source := range keys(m.loadDurations) {
+		fmt.Fprintf(&b, "config_load_duration_seconds{source=%s} %g\n", quote(source), m.loadDurations[source])
+	}
+	counters(&b, "config_load_failures_total", "Total number of failed loads of a config source.", m.loadFailures)
+
+	header(&b, "config_reloads_total", "counter", "Total number of reloads of a config source.")
+	reloads := make([][2]string, 0, len(m.reloads))
+	for k := range m.reloads {
+		reloads = append(reloads, k)
+	}
+	sort.Slice(reloads, func(i, j int) bool {
+		if reloads[i][0] != reloads[j][0] {
+			return reloads[i][0] < reloads[j][0]
+		}
+		return reloads[i][1] < reloads[j][1]
+	})
+	for _, k := range reloads {
+		fmt.Fprintf(&b, "config_reloads_total{source=%s,result=%s} %d\n", quote(k[0]), quote(k[1]), m.reloads[k])
+	}
+
+	counters(&b, "config_merge_failures_total", "Total number of failed merges of a config source.", m.mergeFailures)
+	counters(&b, "config_resolve_failures_total", "Total number of failed resolves of a config source.", m.resolveFailure)
+
+	header(&b, "config_observer_panics_total", "counter", "Total number of observer panics.")
+	fmt.Fprintf(&b, "config_observer_panics_total %d\n", m.observerPanics)
+	header(&b, "config_revision", "gauge", "Current revision of the config.")
+	fmt.Fprintf(&b, "config_revision %d\n", m.revision)
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func header(b *strings.Builder, name, typ, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func counters(b *strings.Builder, name, help string, values map[string]uint64) {
+	header(b, name, "counter", help)
+	for _, source := range keys(values) {
+		fmt.Fprintf(b, "%s{source=%s} %d\n", name, quote(source), values[source])
+	}
+}
+
+func keys[V any](m map[string]V) []string {
+	ks := make([]string, 0, len(m))
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func quote(s string) string {
+	return `"` + labelEscaper.Replace(s) + `"`
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sraphs/config"
+	"github.com/sraphs/config/env"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Setenv("METRICS_TEST_NAME", "app")
+
+	m := New()
+	c := config.New(
+		config.WithSource(env.NewSource("METRICS_TEST_")),
+		config.WithMetrics(m),
+	)
+	defer c.Close()
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	m.Reload("file:a\"b", errors.New("bad"))
+	m.MergeFailure("file:a\"b")
+	m.LoadDuration("flag", time.Second, nil)
+
+	srv := httptest.NewServer(m)
+	defer srv.Close()
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("content type = %q", ct)
+	}
+
+	out := string(body)
+	for _, want := range []string{
+		`config_load_duration_seconds{source="env:METRICS_TEST_"}`,
+		`config_load_duration_seconds{source="flag"} 1`,
+		`config_reloads_total{source="file:a\"b",result="failure"} 1`,
+		`config_merge_failures_total{source="file:a\"b"} 1`,
+		"# TYPE config_observer_panics_total counter",
+		"config_observer_panics_total 0",
+		fmt.Sprintf("config_revision %d", c.Snapshot().Revision()),
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
@@ -2,10 +2,15 @@ package config
 
 import (
 	"fmt"
+	"os"
+	"reflect"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/sraphs/encoding"
+
+	"github.com/sraphs/config/internal/secret"
 )
 
 // Decoder is config decoder.
@@ -18,26 +23,163 @@ type Resolver func(map[string]interface{}) error
 type Option func(*options)
 
 type options struct {
-	sources   []Source
-	decoder   Decoder
-	resolver  Resolver
-	enableLog bool
+	sources    []Source
+	priorities []sourcePriority
+	decoder    Decoder
+	resolver   Resolver
+	logger     Logger
+	metrics    Metrics
+
+	reloadValidators    []func(Config) error
+	reloadErrorHandlers []func(error)
+	errorHandlers       []func(source string, err error)
+	backoff             Backoff
+	debounce            time.Duration
+	durationUnit        time.Duration
+}
+
+type sourcePriority struct {
+	source   Source
+	priority int
 }
 
-// WithLog with config log.
+// WithLog with config log to stdout.
+//
+// Deprecated: use WithLogger.
 func WithLog() Option {
+	return WithLogger(NewStdLogger(os.Stdout))
+}
+
+// WithLogger with config logger, config is silent by default.
+func WithLogger(l Logger) Option {
 	return func(o *options) {
-		o.enableLog = true
+		o.logger = l
 	}
 }
 
 // WithSource with config source.
+// Sources registered later take precedence over earlier ones,
+// unless a priority is given by WithPriority.
 func WithSource(s ...Source) Option {
 	return func(o *options) {
 		o.sources = s
 	}
 }
 
+// WithPriority with explicit priority of a config source.
+// Values of sources with higher priority override lower ones,
+// the default priority of a source is its index in WithSource.
+func WithPriority(s Source, priority int) Option {
+	return func(o *options) {
+		o.priorities = append(o.priorities, sourcePriority{source: s, priority: priority})
+	}
+}
+
+// priority returns the priority of the i-th source.
+func (o options) priority(i int) int {
+	src := o.sources[i]
+	if reflect.TypeOf(src).Comparable() {
+		for j := len(o.priorities) - 1; j >= 0; j-- {
+			if o.priorities[j].source == src {
+				return o.priorities[j].priority
+			}
+		}
+	}
+	return i
+}
+
+// WithReloadValidator with config reload validator.
+// A reloaded config only becomes live when all validators
+// accept it, otherwise the last known good config is kept.
+func WithReloadValidator(v func(Config) error) Option {
+	return func(o *options) {
+		o.reloadValidators = append(o.reloadValidators, v)
+	}
+}
+
+// WithReloadErrorHandler with config reload error handler.
+// It is called when a reload is rejected or fails to merge.
+func WithReloadErrorHandler(h func(error)) Option {
+	return func(o *options) {
+		o.reloadErrorHandlers = append(o.reloadErrorHandlers, h)
+	}
+}
+
+// WithMetrics with config metrics.
+func WithMetrics(m Metrics) Option {
+	return func(o *options) {
+		o.metrics = m
+	}
+}
+
+// WithErrorHandler with config error handler.
+// It is called with the source name when a watcher fails, or when
+// a config of the source fails to merge, resolve or validate.
+func WithErrorHandler(h func(source string, err error)) Option {
+	return func(o *options) {
+		o.errorHandlers = append(o.errorHandlers, h)
+	}
+}
+
+// Backoff is the retry policy of a watcher after a failure,
+// each watcher backs off independently.
+type Backoff struct {
+	// Initial is the delay after the first failure.
+	Initial time.Duration
+	// Max is the upper bound of the delay.
+	Max time.Duration
+	// Multiplier is the factor applied to the delay after each failure.
+	Multiplier float64
+}
+
+// DefaultBackoff is the default watcher backoff.
+var DefaultBackoff = Backoff{
+	Initial:    time.Second,
+	Max:        time.Minute,
+	Multiplier: 2, //nolint:gomnd
+}
+
+// WithBackoff with watcher backoff.
+func WithBackoff(b Backoff) Option {
+	return func(o *options) {
+		o.backoff = b
+	}
+}
+
+// WithReloadDebounce with the window in which the changes of a source
+// are coalesced into one reload, zero disables it.
+func WithReloadDebounce(d time.Duration) Option {
+	return func(o *options) {
+		o.debounce = d
+	}
+}
+
+// WithDurationUnit with the unit of durations given as plain numbers,
+// like "timeout: 30", nanoseconds by default.
+func WithDurationUnit(unit time.Duration) Option {
+	return func(o *options) {
+		o.durationUnit = unit
+	}
+}
+
+// delay returns the delay before the retry after attempt failures.
+func (b Backoff) delay(attempt int) time.Duration {
+	if b.Initial <= 0 {
+		b = DefaultBackoff
+	}
+	if b.Multiplier < 1 {
+		b.Multiplier = 1
+	}
+	d := float64(b.Initial)
+	for i := 0; i < attempt && d < float64(b.Max); i++ {
+		d *= b.Multiplier
+	}
+	if b.Max > 0 && d > float64(b.Max) {
+		return b.Max
+	}
+	return time.Duration(d)
+}
+
 // WithDecoder with config decoder.
 // DefaultDecoder behavior:
 // If KeyValue.Format is non-empty, then KeyValue.Value will be deserialized into map[string]interface{}
@@ -80,11 +222,20 @@ func defaultDecoder(src *Descriptor, target map[string]interface{}) error {
 }
 
 // defaultResolver resolve placeholder in map value,
-// placeholder format in ${key:default}.
+// placeholder format in ${key:default}, or ${file:/path}
+// for the contents of a file, such as a mounted secret.
 func defaultResolver(input map[string]interface{}) error {
+	var err error
 	mapper := func(name string) string {
+		if path, ok := filePlaceholder(name); ok {
+			s, ferr := secret.ReadFile(path)
+			if ferr != nil && err == nil {
+				err = ferr
+			}
+			return s
+		}
 		args := strings.SplitN(strings.TrimSpace(name), ":", 2) //nolint:gomnd
-		if v, has := readValue(input, args[0]); has {
+		if v, has := readValue(input, args[0], 0); has {
 			s, _ := v.String()
 			return s
 		} else if len(args) > 1 { // default value
@@ -119,7 +270,10 @@ func defaultResolver(input map[string]interface{}) error {
 		}
 		return nil
 	}
-	return resolve(input)
+	if rerr := resolve(input); rerr != nil {
+		return rerr
+	}
+	return err
 }
 
 func expand(s string, mapping func(string) string) string {
@@ -3,6 +3,7 @@ package config
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestDefaultDecoder(t *testing.T) {
@@ -197,3 +198,23 @@ func TestDefaultResolver(t *testing.T) {
 		})
 	}
 }
+
+func TestBackoff(t *testing.T) {
+	b := Backoff{Initial: 100 * time.Millisecond, Max: time.Second, Multiplier: 2}
+	expect := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+	for attempt, d := range expect {
+		if actual := b.delay(attempt); actual != d {
+			t.Errorf("attempt %d: expect %v, actual %v", attempt, d, actual)
+		}
+	}
+	if actual := (Backoff{}).delay(0); actual != DefaultBackoff.Initial {
+		t.Errorf("expect default backoff, actual %v", actual)
+	}
+}
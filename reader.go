@@ -5,10 +5,11 @@ import (
 	"encoding/gob"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
-	"github.com/imdario/mergo"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 )
@@ -21,13 +22,31 @@ type Reader interface {
 	Resolve() error
 }
 
+var _ Reader = (*reader)(nil)
+
 type reader struct {
 	opts   options
+	layers []*layer
+	seq    int
 	values map[string]interface{}
-	lock   sync.Mutex
+	// files are referenced by ${file:/path} placeholders
+	files []string
+	lock  sync.Mutex
+}
+
+// layer is the decoded values of a descriptor.
+type layer struct {
+	name     string
+	source   int
+	priority int
+	seq      int
+	values   map[string]interface{}
+	// loose layers have keys derived from names, see looseKeys
+	loose   bool
+	deleted bool
 }
 
-func newReader(opts options) Reader {
+func newReader(opts options) *reader {
 	return &reader{
 		opts:   opts,
 		values: make(map[string]interface{}),
@@ -35,32 +54,137 @@ func newReader(opts options) Reader {
 	}
 }
 
-func (r *reader) Merge(descriptors ...*Descriptor) error {
+// fork returns a copy of r which can be merged
+// without affecting r, the layers are shared as
+// they are never modified once created.
+func (r *reader) fork() *reader {
 	r.lock.Lock()
-	merged, err := cloneMap(r.values)
-	r.lock.Unlock()
-	if err != nil {
-		return err
+	defer r.lock.Unlock()
+	return &reader{
+		opts:   r.opts,
+		layers: append([]*layer(nil), r.layers...),
+		seq:    r.seq,
+		values: r.values,
+		files:  r.files,
 	}
+}
+
+// Merge replaces the layers of the given descriptors, removes the layers
+// of deleted ones and rebuilds the values from all live layers, in order
+// of priority, then of source registration and of merge.
+func (r *reader) Merge(descriptors ...*Descriptor) error {
+	decoded := make([]*layer, 0, len(descriptors))
 	for _, d := range descriptors {
+		if d.Deleted {
+			decoded = append(decoded, &layer{name: d.Name, source: d.source, priority: d.Priority, deleted: true})
+			continue
+		}
 		next := make(map[string]interface{})
 		if err := r.opts.decoder(d, next); err != nil {
 			return fmt.Errorf("config decode error, err: %v, key: %s, value: %s", err, d.Name, string(d.Data))
 		}
-		if err := mergo.Map(&merged, convertMap(next), mergo.WithOverride); err != nil {
-			return fmt.Errorf("config merge error, err: %v, key: %s, value: %s", err, d.Name, string(d.Data))
+		values := convertMap(next).(map[string]interface{})
+		loose := looseKeys(d)
+		if loose {
+			values = expandKeys(values)
 		}
+		decoded = append(decoded, &layer{
+			name:     d.Name,
+			source:   d.source,
+			priority: d.Priority,
+			values:   values,
+			loose:    loose,
+		})
 	}
+
 	r.lock.Lock()
-	r.values = merged
-	r.lock.Unlock()
+	defer r.lock.Unlock()
+
+	seq := r.seq
+	layers := append([]*layer(nil), r.layers...)
+	full := make(map[int]bool)
+	for _, l := range decoded {
+		full[l.source] = fullSet(layers, decoded, l.source)
+	}
+	for _, l := range decoded {
+		i := indexLayer(layers, l)
+		if i >= 0 && !full[l.source] {
+			l.seq = layers[i].seq
+			layers[i] = l
+			continue
+		}
+		seq++
+		l.seq = seq
+		if i >= 0 {
+			layers[i] = l
+			continue
+		}
+		layers = append(layers, l)
+	}
+	live := layers[:0]
+	for _, l := range layers {
+		if !l.deleted {
+			live = append(live, l)
+		}
+	}
+	layers = live
+	sort.SliceStable(layers, func(i, j int) bool {
+		if layers[i].priority != layers[j].priority {
+			return layers[i].priority < layers[j].priority
+		}
+		if layers[i].source != layers[j].source {
+			return layers[i].source < layers[j].source
+		}
+		return layers[i].seq < layers[j].seq
+	})
+
+	merged, err := mergeLayers(layers)
+	if err != nil {
+		return err
+	}
+	r.layers, r.seq, r.values = layers, seq, merged
 	return nil
 }
 
+// fullSet reports whether the decoded layers replace all layers of the
+// source, a full set of a source then also replaces their order, so that
+// e.g. a file created in a config dir is merged in the order of its name.
+func fullSet(layers, decoded []*layer, source int) bool {
+	for _, l := range layers {
+		if l.source == source && indexLayer(decoded, l) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func indexLayer(layers []*layer, l *layer) int {
+	for i, v := range layers {
+		if v.name == l.name && v.source == l.source {
+			return i
+		}
+	}
+	return -1
+}
+
+// mergeLayers merges layers into a new map, later layers override earlier ones.
+func mergeLayers(layers []*layer) (map[string]interface{}, error) {
+	merged := make(map[string]interface{})
+	for _, l := range layers {
+		// clone, the merged values are resolved in place
+		values, err := cloneMap(l.values)
+		if err != nil {
+			return nil, err
+		}
+		mergeValues(merged, values, l.loose)
+	}
+	return merged, nil
+}
+
 func (r *reader) Value(path string) (Value, bool) {
 	r.lock.Lock()
 	defer r.lock.Unlock()
-	return readValue(r.values, path)
+	return readValue(r.values, path, r.opts.durationUnit)
 }
 
 func (r *reader) Source() ([]byte, error) {
@@ -69,9 +193,17 @@ func (r *reader) Source() ([]byte, error) {
 	return marshalJSON(convertMap(r.values))
 }
 
+// cloneValues returns a deep copy of the merged values.
+func (r *reader) cloneValues() (map[string]interface{}, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return cloneMap(r.values)
+}
+
 func (r *reader) Resolve() error {
 	r.lock.Lock()
 	defer r.lock.Unlock()
+	r.files = filePlaceholders(r.values)
 	return r.opts.resolver(r.values)
 }
 
@@ -124,19 +256,21 @@ func convertMap(src interface{}) interface{} {
 
 // readValue read Value in given map[string]interface{}
 // by the given path, will return false if not found.
-func readValue(values map[string]interface{}, path string) (Value, bool) {
+// Durations of the Value given as plain numbers are in unit.
+func readValue(values map[string]interface{}, path string, unit time.Duration) (Value, bool) {
 	var (
 		next = values
 		keys = strings.Split(path, ".")
 		last = len(keys) - 1
 	)
 	for idx, key := range keys {
-		value, ok := next[key]
+		k, ok := lookupKey(next, key)
 		if !ok {
 			return nil, false
 		}
+		value := next[k]
 		if idx == last {
-			av := &atomicValue{}
+			av := &atomicValue{unit: unit}
 			av.Store(value)
 			return av, true
 		}
@@ -73,6 +73,77 @@ func TestReader_Merge(t *testing.T) {
 	}
 }
 
+func TestReader_MergeOrder(t *testing.T) {
+	r := newReader(options{decoder: defaultDecoder, resolver: defaultResolver})
+	desc := func(name, data string) *Descriptor {
+		return &Descriptor{Name: name, Data: []byte(data), Format: "json"}
+	}
+	get := func() string {
+		v, _ := r.Value("level")
+		s, _ := v.String()
+		return s
+	}
+
+	if err := r.Merge(desc("10-base.json", `{"level": "info"}`), desc("20-prod.json", `{"level": "warn"}`)); err != nil {
+		t.Fatal(err)
+	}
+	// a change of one layer keeps its order
+	if err := r.Merge(desc("10-base.json", `{"level": "debug"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if s := get(); s != "warn" {
+		t.Fatalf("expect warn, got %s", s)
+	}
+	// a full set replaces the order
+	if err := r.Merge(
+		desc("05-new.json", `{"level": "error"}`),
+		desc("10-base.json", `{"level": "debug"}`),
+		desc("20-prod.json", `{"level": "warn"}`),
+	); err != nil {
+		t.Fatal(err)
+	}
+	if s := get(); s != "warn" {
+		t.Fatalf("expect warn, got %s", s)
+	}
+	if err := r.Merge(desc("05-new.json", `{"level": "fatal"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if s := get(); s != "warn" {
+		t.Fatalf("expect warn, got %s", s)
+	}
+}
+
+func TestReader_MergeDeleted(t *testing.T) {
+	r := newReader(options{decoder: defaultDecoder, resolver: defaultResolver})
+	if err := r.Merge(
+		&Descriptor{Name: "a", Data: []byte(`{"x": 1, "y": {"z": 1}}`), Format: "json"},
+		&Descriptor{Name: "b", Data: []byte(`{"x": 2, "y": {"w": 2}}`), Format: "json"},
+	); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Merge(&Descriptor{Name: "b", Deleted: true}); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := r.Value("x"); v == nil {
+		t.Fatal("expect x")
+	} else if x, _ := v.Int(); x != 1 {
+		t.Fatalf("expect 1, got %d", x)
+	}
+	if _, ok := r.Value("y.w"); ok {
+		t.Fatal("expect y.w removed")
+	}
+	if len(r.layers) != 1 {
+		t.Fatalf("expect one layer, got %d", len(r.layers))
+	}
+	// a tombstone of an unknown descriptor is a no-op
+	if err := r.Merge(&Descriptor{Name: "c", Deleted: true}); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.layers) != 1 {
+		t.Fatalf("expect one layer, got %d", len(r.layers))
+	}
+}
+
 func TestReader_Value(t *testing.T) {
 	opts := options{
 		decoder: func(d *Descriptor, v map[string]interface{}) error {
@@ -0,0 +1,272 @@
+package config
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var (
+	protoMessageType    = reflect.TypeOf((*proto.Message)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// scanJSON unmarshals data into v, normalizing the values against
+// the type of v beforehand, so loosely typed values of sources like
+// env and flag can be decoded. Plain number durations are in unit.
+func scanJSON(data []byte, v interface{}, unit time.Duration) error {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if m, ok := protoTarget(v); ok {
+		data, err := json.Marshal(normalizeProto(m.ProtoReflect().Descriptor(), raw, unit))
+		if err != nil {
+			return err
+		}
+		return unmarshalJSON(data, m)
+	}
+	data, err := json.Marshal(normalize(reflect.TypeOf(v), raw, unit))
+	if err != nil {
+		return err
+	}
+	return unmarshalJSON(data, v)
+}
+
+// protoTarget returns the proto message pointed by v,
+// allocating the intermediate pointers.
+func protoTarget(v interface{}) (proto.Message, bool) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		if m, ok := rv.Interface().(proto.Message); ok {
+			return m, true
+		}
+		elem := rv.Elem()
+		if elem.Kind() != reflect.Ptr || !elem.Type().Implements(protoMessageType) {
+			return nil, false
+		}
+		if elem.IsNil() {
+			elem.Set(reflect.New(elem.Type().Elem()))
+		}
+		rv = elem
+	}
+	return nil, false
+}
+
+// normalize returns a copy of v converted to match the target type rt:
+// keys are matched to fields loosely, durations like "1s" or "PT5M" are
+// converted to nanoseconds and scalar strings to numbers or bools.
+func normalize(rt reflect.Type, v interface{}, unit time.Duration) interface{} {
+	if rt == nil || v == nil {
+		return v
+	}
+	for rt.Kind() == reflect.Ptr {
+		if rt.Implements(protoMessageType) {
+			if md, ok := reflect.Zero(rt).Interface().(proto.Message); ok {
+				return normalizeProto(md.ProtoReflect().Descriptor(), v, unit)
+			}
+			return v
+		}
+		rt = rt.Elem()
+	}
+	if rt == durationType {
+		av := &atomicValue{unit: unit}
+		av.Store(v)
+		if d, err := av.Duration(); err == nil {
+			return int64(d)
+		}
+		return v
+	}
+	if rt == timeType || reflect.PtrTo(rt).Implements(jsonUnmarshalerType) || reflect.PtrTo(rt).Implements(textUnmarshalerType) {
+		return v
+	}
+
+	switch rt.Kind() {
+	case reflect.Struct:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return v
+		}
+		fields := jsonFields(rt)
+		out := make(map[string]interface{}, len(m))
+		for k, item := range m {
+			if f, ok := fields[foldKey(k)]; ok {
+				out[f.name] = normalize(f.typ, item, unit)
+			} else {
+				out[k] = item
+			}
+		}
+		return out
+	case reflect.Map:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return v
+		}
+		out := make(map[string]interface{}, len(m))
+		for k, item := range m {
+			out[k] = normalize(rt.Elem(), item, unit)
+		}
+		return out
+	case reflect.Slice, reflect.Array:
+		if rt.Kind() == reflect.Slice && rt.Elem().Kind() == reflect.Uint8 {
+			return v
+		}
+		items, err := sliceValue(newValue(v))
+		if err != nil {
+			return v
+		}
+		out := make([]interface{}, len(items))
+		for i, item := range items {
+			out[i] = normalize(rt.Elem(), item.Load(), unit)
+		}
+		return out
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if _, isMap := v.(map[string]interface{}); isMap {
+			return v
+		}
+		if _, isSlice := v.([]interface{}); isSlice {
+			return v
+		}
+		if cv, err := convert(newValue(v), rt); err == nil {
+			return cv.Interface()
+		}
+	}
+	return v
+}
+
+// normalizeProto is like normalize but for proto messages,
+// keys are matched to the json or proto names of the fields.
+func normalizeProto(md protoreflect.MessageDescriptor, v interface{}, unit time.Duration) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	fields := make(map[string]protoreflect.FieldDescriptor, md.Fields().Len())
+	for i := 0; i < md.Fields().Len(); i++ {
+		fd := md.Fields().Get(i)
+		fields[foldKey(string(fd.Name()))] = fd
+		fields[foldKey(fd.JSONName())] = fd
+	}
+	out := make(map[string]interface{}, len(m))
+	for k, item := range m {
+		fd, ok := fields[foldKey(k)]
+		if !ok {
+			out[k] = item
+			continue
+		}
+		switch {
+		case fd.IsList():
+			items, err := sliceValue(newValue(item))
+			if err != nil {
+				out[fd.JSONName()] = item
+				continue
+			}
+			list := make([]interface{}, len(items))
+			for i, it := range items {
+				list[i] = normalizeProtoValue(fd, it.Load(), unit)
+			}
+			out[fd.JSONName()] = list
+		case fd.IsMap():
+			mm, ok := item.(map[string]interface{})
+			if !ok {
+				out[fd.JSONName()] = item
+				continue
+			}
+			values := make(map[string]interface{}, len(mm))
+			for mk, mv := range mm {
+				values[mk] = normalizeProtoValue(fd.MapValue(), mv, unit)
+			}
+			out[fd.JSONName()] = values
+		default:
+			out[fd.JSONName()] = normalizeProtoValue(fd, item, unit)
+		}
+	}
+	return out
+}
+
+func normalizeProtoValue(fd protoreflect.FieldDescriptor, v interface{}, unit time.Duration) interface{} {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if fd.Message().FullName() == "google.protobuf.Duration" {
+			av := &atomicValue{unit: unit}
+			av.Store(v)
+			if d, err := av.Duration(); err == nil {
+				return formatProtoDuration(d)
+			}
+			return v
+		}
+		return normalizeProto(fd.Message(), v, unit)
+	case protoreflect.BoolKind:
+		if s, ok := v.(string); ok {
+			if b, err := strconv.ParseBool(s); err == nil {
+				return b
+			}
+		}
+	case protoreflect.StringKind:
+		switch v.(type) {
+		case bool, float64:
+			return fmt.Sprint(v)
+		}
+	}
+	return v
+}
+
+// formatProtoDuration formats d in the protobuf JSON format, e.g. "1.5s".
+func formatProtoDuration(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s" //nolint:gomnd
+}
+
+type jsonField struct {
+	name string
+	typ  reflect.Type
+}
+
+// jsonFields returns the fields of struct type rt keyed by their
+// folded json name, and by the name of their `config` tag.
+func jsonFields(rt reflect.Type) map[string]jsonField {
+	fields := make(map[string]jsonField, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for k, t := range jsonFields(ft) {
+					if _, ok := fields[k]; !ok {
+						fields[k] = t
+					}
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		field := jsonField{name: name, typ: f.Type}
+		fields[foldKey(name)] = field
+		if alias := parseFieldTag(f.Tag.Get("config")).name; alias != "" {
+			fields[foldKey(alias)] = field
+		}
+	}
+	return fields
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Snapshot is an immutable view of the config at a revision,
+// it is not affected by reloads happening after it is taken.
+type Snapshot interface {
+	// Revision is incremented each time the config changes.
+	Revision() uint64
+	// Hash is the sha256 of the config content.
+	Hash() string
+	Get(key string) Value
+	Scan(v interface{}) error
+}
+
+var _ Snapshot = (*snapshot)(nil)
+
+type snapshot struct {
+	revision uint64
+	hash     string
+	data     []byte
+	values   map[string]interface{}
+	unit     time.Duration
+	err      error
+}
+
+// Snapshot returns the snapshot of the current revision, snapshots
+// are shared between callers until the config changes.
+func (c *config) Snapshot() Snapshot {
+	r, revision := c.live()
+
+	c.snapMu.Lock()
+	defer c.snapMu.Unlock()
+	if c.snap != nil && c.snap.revision == revision {
+		return c.snap
+	}
+
+	data, err := r.Source()
+	if err != nil {
+		return &snapshot{revision: revision, err: err}
+	}
+	values, err := r.cloneValues()
+	if err != nil {
+		return &snapshot{revision: revision, err: err}
+	}
+	sum := sha256.Sum256(data)
+	c.snap = &snapshot{
+		revision: revision,
+		hash:     hex.EncodeToString(sum[:]),
+		data:     data,
+		values:   values,
+		unit:     c.opts.durationUnit,
+	}
+	return c.snap
+}
+
+func (s *snapshot) Revision() uint64 {
+	return s.revision
+}
+
+func (s *snapshot) Hash() string {
+	return s.hash
+}
+
+func (s *snapshot) Get(key string) Value {
+	if s.err != nil {
+		return &errValue{err: s.err}
+	}
+	if v, ok := readValue(s.values, key, s.unit); ok {
+		return v
+	}
+	return &errValue{err: ErrNotFound}
+}
+
+func (s *snapshot) Scan(v interface{}) error {
+	if s.err != nil {
+		return s.err
+	}
+	return scanSource(s.data, v, s.unit)
+}
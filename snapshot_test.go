@@ -0,0 +1,56 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfig_Snapshot(t *testing.T) {
+	src := newTestChanSource(`{"server": {"addr": ":80", "port": 80}}`)
+	c := New(WithSource(src))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s1 := c.Snapshot()
+	if s1 != c.Snapshot() {
+		t.Fatal("expect the same snapshot for the same revision")
+	}
+
+	reloaded := make(chan struct{}, 1)
+	_ = c.Watch(func(Config) { reloaded <- struct{}{} })
+	src.next <- []*Descriptor{{Name: "json", Data: []byte(`{"server": {"addr": ":8080", "port": 8080}}`), Format: "json"}}
+	select {
+	case <-reloaded:
+	case <-time.After(time.Second):
+		t.Fatal("observer is not called")
+	}
+
+	s2 := c.Snapshot()
+	if s2.Revision() <= s1.Revision() {
+		t.Fatalf("expect revision increased, got %d and %d", s1.Revision(), s2.Revision())
+	}
+	if s2.Hash() == s1.Hash() || len(s1.Hash()) != 64 {
+		t.Fatalf("unexpected hashes %q and %q", s1.Hash(), s2.Hash())
+	}
+
+	var conf struct {
+		Server struct {
+			Addr string `json:"addr"`
+			Port int    `json:"port"`
+		} `json:"server"`
+	}
+	if err := s1.Scan(&conf); err != nil {
+		t.Fatal(err)
+	}
+	if port, _ := s1.Get("server.port").Int(); port != 80 || conf.Server.Addr != ":80" || conf.Server.Port != 80 {
+		t.Fatalf("expect snapshot pinned to the old config, got %d %+v", port, conf)
+	}
+	if port, _ := s2.Get("server.port").Int(); port != 8080 {
+		t.Fatalf("expect 8080, got %d", port)
+	}
+	if err := s1.Scan(conf); err != ErrScanNeedPtr {
+		t.Fatalf("expect ErrScanNeedPtr, got %v", err)
+	}
+}
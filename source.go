@@ -1,6 +1,9 @@
 package config
 
 import (
+	"context"
+	"sync"
+
 	"github.com/sraphs/encoding"
 )
 
@@ -10,19 +13,92 @@ type Source interface {
 	Watch() (Watcher, error)
 }
 
+// SourceContext is a Source which supports context cancellation.
+// Config prefers these methods over Load and Watch when available.
+type SourceContext interface {
+	Source
+	LoadContext(ctx context.Context) ([]*Descriptor, error)
+	WatchContext(ctx context.Context) (Watcher, error)
+}
+
 // Watcher watches a source for changes.
 type Watcher interface {
 	Next() ([]*Descriptor, error)
 	Stop() error
 }
 
+// WatcherContext is a Watcher which supports context cancellation.
+// Config prefers NextContext over Next when available.
+type WatcherContext interface {
+	Watcher
+	NextContext(ctx context.Context) ([]*Descriptor, error)
+}
+
 // Descriptor is file or env or flag descriptor.
 type Descriptor struct {
 	Name   string
 	Format string
 	Data   []byte
+	// Priority is set by Config from the priority of the source,
+	// descriptors with higher priority override lower ones.
+	Priority int
+	// Normalized marks descriptors whose keys are normalized names, like
+	// those of env variables, their dotted keys are expanded into nested
+	// maps and matched loosely against the keys of other descriptors.
+	Normalized bool
+	// Deleted marks a tombstone of a removed descriptor,
+	// whose values are removed from the config.
+	Deleted bool
+	// source is the index of the source in WithSource set by Config,
+	// descriptors are identified by their source and name.
+	source int
 }
 
 func (d *Descriptor) GetCodec() encoding.Codec {
 	return encoding.GetCodec(d.Format)
 }
+
+func loadSource(ctx context.Context, src Source) ([]*Descriptor, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if s, ok := src.(SourceContext); ok {
+		return s.LoadContext(ctx)
+	}
+	return src.Load()
+}
+
+func watchSource(ctx context.Context, src Source) (Watcher, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if s, ok := src.(SourceContext); ok {
+		return s.WatchContext(ctx)
+	}
+	return src.Watch()
+}
+
+// onceWatcher makes Stop idempotent, since a watcher
+// may be stopped by both Close and a cancelled context.
+type onceWatcher struct {
+	Watcher
+	once sync.Once
+	err  error
+}
+
+func (w *onceWatcher) NextContext(ctx context.Context) ([]*Descriptor, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if wc, ok := w.Watcher.(WatcherContext); ok {
+		return wc.NextContext(ctx)
+	}
+	return w.Watcher.Next()
+}
+
+func (w *onceWatcher) Stop() error {
+	w.once.Do(func() {
+		w.err = w.Watcher.Stop()
+	})
+	return w.err
+}
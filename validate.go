@@ -0,0 +1,277 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldError is an invalid or missing config key found by Scan.
+type FieldError struct {
+	Key string
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Key, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// FieldErrors aggregates all the field errors found by Scan.
+type FieldErrors []*FieldError
+
+func (e FieldErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// fieldTag is the parsed `config:"name,default=value,required"` tag.
+type fieldTag struct {
+	name       string
+	def        string
+	hasDefault bool
+	required   bool
+}
+
+func parseFieldTag(tag string) fieldTag {
+	parts := strings.Split(tag, ",")
+	ft := fieldTag{name: parts[0]}
+	inDefault := false
+	for _, p := range parts[1:] {
+		switch {
+		case p == "required":
+			ft.required, inDefault = true, false
+		case strings.HasPrefix(p, "default="):
+			ft.def, ft.hasDefault, inDefault = strings.TrimPrefix(p, "default="), true, true
+		case inDefault:
+			// a list default such as default=a,b,c
+			ft.def += "," + p
+		}
+	}
+	return ft
+}
+
+// checkStruct applies the `config` defaults of v and validates its
+// `config` and `validate` tags, returning every failing key at once.
+// A key is missing if it is absent from the scanned data src and its
+// field is zero, so explicit zero values are kept. Plain number
+// durations of the defaults are in unit.
+func checkStruct(v interface{}, src interface{}, unit time.Duration) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	var errs FieldErrors
+	checkFields(rv, "", src, unit, &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func checkFields(rv reflect.Value, prefix string, src interface{}, unit time.Duration, errs *FieldErrors) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		fv := rv.Field(i)
+		tag := parseFieldTag(f.Tag.Get("config"))
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		key := tag.name
+		if key == "" {
+			key = name
+		}
+		if key == "" || key == "-" {
+			key = f.Name
+		}
+		if prefix != "" && !f.Anonymous {
+			key = prefix + "." + key
+		} else if f.Anonymous {
+			key = prefix
+		}
+
+		sub, present := src, true
+		if !f.Anonymous || name != "" {
+			sub, present = sourceField(src, f, tag)
+		}
+		missing := !present && fv.IsZero()
+
+		if tag.hasDefault && missing {
+			def := &atomicValue{unit: unit}
+			def.Store(tag.def)
+			dv, err := convert(def, f.Type)
+			if err != nil {
+				*errs = append(*errs, &FieldError{Key: key, Err: fmt.Errorf("invalid default %q: %v", tag.def, err)})
+				continue
+			}
+			fv.Set(dv)
+			missing = false
+		}
+
+		if tag.required && missing {
+			*errs = append(*errs, &FieldError{Key: key, Err: ErrRequired})
+			continue
+		}
+
+		if rules := f.Tag.Get("validate"); rules != "" {
+			if err := validate(fv, rules); err != nil {
+				*errs = append(*errs, &FieldError{Key: key, Err: err})
+				continue
+			}
+		}
+
+		checkNested(fv, key, sub, unit, errs)
+	}
+}
+
+// sourceField returns the value of field f in the scanned data src,
+// matching its keys loosely like Scan does, null values are absent.
+func sourceField(src interface{}, f reflect.StructField, tag fieldTag) (interface{}, bool) {
+	m, ok := src.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" {
+		name = f.Name
+	}
+	for _, key := range []string{name, tag.name} {
+		if key == "" || key == "-" {
+			continue
+		}
+		if k, ok := lookupKey(m, key); ok && m[k] != nil {
+			return m[k], true
+		}
+	}
+	return nil, false
+}
+
+func checkNested(fv reflect.Value, key string, src interface{}, unit time.Duration, errs *FieldErrors) {
+	switch fv.Kind() {
+	case reflect.Ptr:
+		if !fv.IsNil() {
+			checkNested(fv.Elem(), key, src, unit, errs)
+		}
+	case reflect.Struct:
+		if fv.Type() != timeType {
+			checkFields(fv, key, src, unit, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		items, _ := src.([]interface{})
+		for i := 0; i < fv.Len(); i++ {
+			var item interface{}
+			if i < len(items) {
+				item = items[i]
+			}
+			checkNested(fv.Index(i), fmt.Sprintf("%s[%d]", key, i), item, unit, errs)
+		}
+	}
+}
+
+// validate checks fv against rules like "min=1,max=65535,oneof=a|b",
+// a nil pointer is empty and fails the rules unless omitempty.
+func validate(fv reflect.Value, rules string) error {
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch name {
+		case "omitempty":
+			if fv.IsZero() {
+				return nil
+			}
+			continue
+		case "":
+			continue
+		}
+		if !reflect.Indirect(fv).IsValid() {
+			return ErrRequired
+		}
+		switch name {
+		case "min", "max":
+			cmp, err := compare(fv, arg)
+			if err != nil {
+				return err
+			}
+			if name == "min" && cmp < 0 {
+				return fmt.Errorf("must be at least %s", arg)
+			}
+			if name == "max" && cmp > 0 {
+				return fmt.Errorf("must be at most %s", arg)
+			}
+		case "oneof":
+			s := fmt.Sprint(reflect.Indirect(fv).Interface())
+			found := false
+			for _, opt := range strings.Split(arg, "|") {
+				if s == opt {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("must be one of %s, got %q", strings.ReplaceAll(arg, "|", ", "), s)
+			}
+		default:
+			return fmt.Errorf("unknown validate rule: %s", name)
+		}
+	}
+	return nil
+}
+
+// compare compares fv with arg, strings, slices and maps
+// are compared by their length.
+func compare(fv reflect.Value, arg string) (int, error) {
+	fv = reflect.Indirect(fv)
+	switch fv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return 0, fmt.Errorf("invalid length bound %q", arg)
+		}
+		return compareFloat(float64(fv.Len()), float64(n)), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		bound, err := convert(newValue(arg), fv.Type())
+		if err != nil {
+			return 0, fmt.Errorf("invalid bound %q: %v", arg, err)
+		}
+		return compareFloat(toFloat(fv), toFloat(bound)), nil
+	}
+	return 0, fmt.Errorf("min and max are not supported for %v", fv.Type())
+}
+
+func toFloat(v reflect.Value) float64 {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint())
+	}
+	return v.Float()
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConfig_ScanValidate(t *testing.T) {
+	type server struct {
+		Addr    string        `json:"addr" config:"addr,required"`
+		Port    int           `json:"port" config:"port,default=8080" validate:"min=1,max=65535"`
+		Timeout time.Duration `json:"timeout" config:"timeout,default=1s" validate:"max=10s"`
+	}
+	type conf struct {
+		Server server   `json:"server"`
+		Level  string   `json:"level" config:"level,default=info" validate:"oneof=debug|info|warn"`
+		Hosts  []string `config:"hosts,default=a,b"`
+		Name   string   `config:"app_name" validate:"omitempty,min=3"`
+	}
+
+	c := New(WithSource(newTestJSONSource(`{"server": {"addr": ":80"}, "app_name": "sraph"}`)))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var v conf
+	if err := c.Scan(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Server.Port != 8080 || v.Server.Timeout != time.Second || v.Level != "info" {
+		t.Fatalf("defaults are not applied: %+v", v)
+	}
+	if len(v.Hosts) != 2 || v.Hosts[1] != "b" {
+		t.Fatalf("list default is not applied: %v", v.Hosts)
+	}
+	if v.Name != "sraph" {
+		t.Fatalf("config tag name is not honored: %q", v.Name)
+	}
+
+	c = New(WithSource(newTestJSONSource(`{"server": {"port": 70000, "timeout": "1m"}, "level": "trace", "app_name": "x"}`)))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	err := c.Scan(&conf{})
+	var errs FieldErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expect FieldErrors, got %v", err)
+	}
+	keys := make(map[string]error)
+	for _, fe := range errs {
+		keys[fe.Key] = fe.Err
+	}
+	for _, key := range []string{"server.addr", "server.port", "server.timeout", "level", "app_name"} {
+		if _, ok := keys[key]; !ok {
+			t.Errorf("expect error for %s, got %v", key, err)
+		}
+	}
+	if !errors.Is(keys["server.addr"], ErrRequired) {
+		t.Errorf("expect ErrRequired, got %v", keys["server.addr"])
+	}
+}
+
+func TestConfig_ScanExplicitZero(t *testing.T) {
+	type conf struct {
+		Enabled bool   `json:"enabled" config:"enabled,default=true"`
+		Port    int    `json:"port" config:"port,default=8080"`
+		Name    string `json:"name" config:"name,required"`
+	}
+
+	c := New(WithSource(newTestJSONSource(`{"enabled": false, "port": 0, "name": ""}`)))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var v conf
+	if err := c.Scan(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Enabled || v.Port != 0 || v.Name != "" {
+		t.Fatalf("explicit zero values are overridden: %+v", v)
+	}
+
+	c = New(WithSource(newTestJSONSource(`{}`)))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	v = conf{}
+	err := c.Scan(&v)
+	var errs FieldErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Key != "name" || !errors.Is(errs[0], ErrRequired) {
+		t.Fatalf("expect ErrRequired for name, got %v", err)
+	}
+	if !v.Enabled || v.Port != 8080 {
+		t.Fatalf("defaults are not applied: %+v", v)
+	}
+}
+
+func TestConfig_ScanValidateNilPointer(t *testing.T) {
+	type conf struct {
+		Port  *int    `json:"port" validate:"min=1"`
+		Level *string `json:"level" validate:"oneof=debug|info"`
+		Name  *string `json:"name" validate:"omitempty,min=3"`
+	}
+
+	c := New(WithSource(newTestJSONSource(`{}`)))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	err := c.Scan(&conf{})
+	var errs FieldErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("expect errors for port and level, got %v", err)
+	}
+	for i, key := range []string{"port", "level"} {
+		if errs[i].Key != key || !errors.Is(errs[i], ErrRequired) {
+			t.Errorf("expect ErrRequired for %s, got %v", key, errs[i])
+		}
+	}
+
+	c = New(WithSource(newTestJSONSource(`{"port": 80, "level": "info"}`)))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var v conf
+	if err := c.Scan(&v); err != nil {
+		t.Fatal(err)
+	}
+	if *v.Port != 80 || *v.Level != "info" || v.Name != nil {
+		t.Fatalf("unexpected config %+v", v)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	stdjson "encoding/json"
 	"fmt"
 	"reflect"
+	"regexp"
 	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -17,6 +19,8 @@ var (
 	_ Value = (*errValue)(nil)
 )
 
+var isoDurationRegexp = regexp.MustCompile(`^([-+])?P(?:(\d+(?:\.\d+)?)W)?(?:(\d+(?:\.\d+)?)D)?(?:T(?:(\d+(?:\.\d+)?)H)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
 // Value is config value interface.
 type Value interface {
 	Bool() (bool, error)
@@ -33,6 +37,8 @@ type Value interface {
 
 type atomicValue struct {
 	atomic.Value
+	// unit of durations given as plain numbers, nanoseconds if zero
+	unit time.Duration
 }
 
 func (v *atomicValue) Bool() (bool, error) {
@@ -81,7 +87,7 @@ func (v *atomicValue) Slice() ([]Value, error) {
 	if vals, ok := v.Load().([]interface{}); ok {
 		var slices []Value
 		for _, val := range vals {
-			a := &atomicValue{}
+			a := &atomicValue{unit: v.unit}
 			a.Store(val)
 			slices = append(slices, a)
 		}
@@ -94,7 +100,7 @@ func (v *atomicValue) Map() (map[string]Value, error) {
 	if vals, ok := v.Load().(map[string]interface{}); ok {
 		m := make(map[string]Value)
 		for key, val := range vals {
-			a := &atomicValue{}
+			a := &atomicValue{unit: v.unit}
 			a.Store(val)
 			m[key] = a
 		}
@@ -152,15 +158,105 @@ func (v *atomicValue) String() (string, error) {
 }
 
 func (v *atomicValue) Duration() (time.Duration, error) {
+	unit := v.unit
+	if unit <= 0 {
+		unit = time.Nanosecond
+	}
+	switch val := v.Load().(type) {
+	case time.Duration:
+		return val, nil
+	case string:
+		return parseDuration(val, unit)
+	case float32:
+		return time.Duration(float64(val) * float64(unit)), nil
+	case float64:
+		return time.Duration(val * float64(unit)), nil
+	case map[string]interface{}:
+		// protobuf Duration marshaled as a message
+		return durationFromMap(val)
+	}
 	val, err := v.Int()
 	if err != nil {
 		return 0, err
 	}
-	return time.Duration(val), nil
+	return time.Duration(val) * unit, nil
+}
+
+// parseDuration parses Go duration strings ("1m30s"), protobuf JSON
+// durations ("1.5s"), ISO-8601 durations ("PT5M") and plain numbers
+// in unit.
+func parseDuration(s string, unit time.Duration) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, nil
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil { //nolint:gomnd
+		return time.Duration(i) * unit, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil { //nolint:gomnd
+		return time.Duration(f * float64(unit)), nil
+	}
+	if d, ok := parseISODuration(s); ok {
+		return d, nil
+	}
+	return 0, fmt.Errorf("invalid duration: %q", s)
+}
+
+// parseISODuration parses ISO-8601 durations without years
+// and months, their length is ambiguous.
+func parseISODuration(s string) (time.Duration, bool) {
+	m := isoDurationRegexp.FindStringSubmatch(strings.ToUpper(s))
+	if m == nil || strings.HasSuffix(m[0], "T") {
+		return 0, false
+	}
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var (
+		d     float64
+		found bool
+	)
+	for i, unit := range units {
+		if m[i+2] == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(m[i+2], 64) //nolint:gomnd
+		if err != nil {
+			return 0, false
+		}
+		d += f * float64(unit)
+		found = true
+	}
+	if !found {
+		return 0, false
+	}
+	if m[1] == "-" {
+		d = -d
+	}
+	return time.Duration(d), true
+}
+
+func durationFromMap(m map[string]interface{}) (time.Duration, error) {
+	var d time.Duration
+	for k, v := range m {
+		av := &atomicValue{}
+		av.Store(v)
+		i, err := av.Int()
+		if err != nil {
+			return 0, err
+		}
+		switch k {
+		case "seconds":
+			d += time.Duration(i) * time.Second
+		case "nanos":
+			d += time.Duration(i)
+		default:
+			return 0, fmt.Errorf("invalid duration field: %s", k)
+		}
+	}
+	return d, nil
 }
 
 func (v *atomicValue) Scan(obj interface{}) error {
-	data, err := stdjson.Marshal(v.Load())
+	data, err := stdjson.Marshal(normalize(reflect.TypeOf(obj), v.Load(), v.unit))
 	if err != nil {
 		return err
 	}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -154,6 +155,47 @@ func Test_atomicValue_Duration(t *testing.T) {
 			t.Fatal(`b is not equal to time.Duration(5)`)
 		}
 	}
+
+	tests := []struct {
+		in     interface{}
+		expect time.Duration
+	}{
+		{"1s", time.Second},
+		{"500ms", 500 * time.Millisecond},
+		{"1.5s", 1500 * time.Millisecond},
+		{"1h2m3s", time.Hour + 2*time.Minute + 3*time.Second},
+		{"PT5M", 5 * time.Minute},
+		{"P1DT1.5H", 25*time.Hour + 30*time.Minute},
+		{"-PT10S", -10 * time.Second},
+		{"100", 100},
+		{float64(10), 10},
+		{map[string]interface{}{"seconds": float64(1), "nanos": float64(2)}, time.Second + 2},
+	}
+	for _, test := range tests {
+		v := atomicValue{}
+		v.Store(test.in)
+		b, err := v.Duration()
+		if err != nil {
+			t.Fatalf("%v: %v", test.in, err)
+		}
+		if b != test.expect {
+			t.Fatalf("%v: expect %v, got %v", test.in, test.expect, b)
+		}
+	}
+
+	for _, x := range []interface{}{"bbb", "P1Y", "PT", true} {
+		v := atomicValue{}
+		v.Store(x)
+		if _, err := v.Duration(); err == nil {
+			t.Fatalf("%v: err is nil", x)
+		}
+	}
+
+	v := atomicValue{unit: time.Millisecond}
+	v.Store(int64(5))
+	if b, err := v.Duration(); err != nil || b != 5*time.Millisecond {
+		t.Fatalf("expect 5ms, got %v %v", b, err)
+	}
 }
 
 func Test_atomicValue_Slice(t *testing.T) {
@@ -222,4 +264,16 @@ func Test_atomicValue_Scan(t *testing.T) {
 	if err != nil {
 		t.Fatal(`err is not nil`)
 	}
+
+	var d struct {
+		Timeout  time.Duration   `json:"timeout"`
+		Backoffs []time.Duration `json:"backoffs"`
+	}
+	v.Store(map[string]interface{}{"timeout": "PT1M", "backoffs": []interface{}{"1s", "500ms"}})
+	if err = v.Scan(&d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Timeout != time.Minute || !reflect.DeepEqual(d.Backoffs, []time.Duration{time.Second, 500 * time.Millisecond}) {
+		t.Fatalf("unexpected durations: %+v", d)
+	}
 }